@@ -8,17 +8,26 @@ import (
 
 type AndQuery struct {
 	queries []Query
-	not     Query
+	nots    []Query
 	docId   int32
 	leading Query
 	boost   float32
+	// bitmaps of the RoaringTerm queries intersected upfront, used as
+	// the leading query
+	intersection *RoaringTermQuery
 }
 
-// Creates AND NOT query
+// Creates AND NOT query, use AddNot() to exclude more queries
 func AndNot(not Query, queries ...Query) *AndQuery {
 	return And(queries...).SetNot(not)
 }
 
+// Creates query that matches all documents from 0 to maxDoc
+// (exclusive) except the ones matching any of the not queries
+func Not(maxDoc int32, not ...Query) *AndQuery {
+	return And(MatchAll(maxDoc)).AddNot(not...)
+}
+
 // Creates AND query
 func And(queries ...Query) *AndQuery {
 	a := &AndQuery{
@@ -50,14 +59,52 @@ func (q *AndQuery) sortSubqueries() {
 	if len(q.queries) > 0 {
 		q.leading = q.queries[0]
 	}
+	q.intersectBitmaps()
+}
+
+// AND of two or more RoaringTerm queries is the intersection of their
+// bitmaps, which is a lot faster than leapfrogging the iterators, the
+// queries are still advanced to each match so they can score it
+func (q *AndQuery) intersectBitmaps() {
+	q.intersection = nil
+	var intersection *Bitmap
+	n := 0
+	for _, s := range q.queries {
+		if r, ok := s.(*RoaringTermQuery); ok && r.docId == NOT_READY {
+			if intersection == nil {
+				intersection = r.bitmap
+			} else {
+				intersection = intersection.And(r.bitmap)
+			}
+			n++
+		}
+	}
+	if n > 1 {
+		q.intersection = RoaringTerm(0, "", intersection)
+		q.leading = q.intersection
+	}
 }
 
 func (q *AndQuery) GetDocId() int32 {
 	return q.docId
 }
 
+// Replaces all the excluded queries with not, nil removes them
 func (q *AndQuery) SetNot(not Query) *AndQuery {
-	q.not = not
+	q.nots = nil
+	if not != nil {
+		q.nots = append(q.nots, not)
+	}
+	return q
+}
+
+// Excludes the documents matching any of the not queries
+func (q *AndQuery) AddNot(not ...Query) *AndQuery {
+	for _, n := range not {
+		if n != nil {
+			q.nots = append(q.nots, n)
+		}
+	}
 	return q
 }
 
@@ -85,12 +132,38 @@ func (q *AndQuery) Score() float32 {
 	return score * q.boost
 }
 
+func (q *AndQuery) UpperBound() float32 {
+	sum := float32(0)
+	for _, s := range q.queries {
+		sum += s.UpperBound()
+	}
+	return boostUpperBound(sum, q.boost)
+}
+
+func (q *AndQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE || len(q.queries) == 0 {
+		return explainNotReady(q.docId, "and")
+	}
+
+	details := []*Explanation{}
+	for _, s := range q.queries {
+		details = append(details, s.Explain())
+	}
+	for _, not := range q.nots {
+		details = append(details, explainNoMatch("must not, excluded when matching "+not.String()))
+	}
+	return explainBoost(explainMatch(q.Score(), "and, sum of:", details...), q.boost)
+}
+
 func (q *AndQuery) nextAndedDoc(target int32) int32 {
+	// the leading query is used in caller
 	start := 1
+	if q.intersection != nil {
+		start = 0
+	}
 	n := len(q.queries)
 AGAIN:
 	for {
-		// initial iteration skips queries[0], because it is used in caller
 		for i := start; i < n; i++ {
 			subQuery := q.queries[i]
 			subQueryDocId := subQuery.GetDocId()
@@ -104,13 +177,19 @@ AGAIN:
 
 			target = q.leading.Advance(subQueryDocId)
 
-			i = 0 //restart the loop from the first query
+			i = start - 1 //restart the loop from the first query
 		}
 
-		if q.not != nil && q.not.GetDocId() != NO_MORE && target != NO_MORE {
-			if q.not.Advance(target) == target {
-				target = q.leading.Advance(target + 1)
-				continue AGAIN
+		if target != NO_MORE {
+			for _, not := range q.nots {
+				notDocId := not.GetDocId()
+				if notDocId < target {
+					notDocId = not.Advance(target)
+				}
+				if notDocId == target {
+					target = q.leading.Advance(target + 1)
+					continue AGAIN
+				}
 			}
 		}
 
@@ -125,8 +204,8 @@ func (q *AndQuery) String() string {
 		out = append(out, v.String())
 	}
 	s := strings.Join(out, " AND ")
-	if q.not != nil {
-		s = fmt.Sprintf("%s -(%s)", s, q.not.String())
+	for _, not := range q.nots {
+		s = fmt.Sprintf("%s -(%s)", s, not.String())
 	}
 	return "{" + s + "}"
 }
@@ -148,3 +227,49 @@ func (q *AndQuery) Next() int32 {
 
 	return q.nextAndedDoc(q.leading.Next())
 }
+
+func (q *AndQuery) Err() error {
+	if err := firstErr(q.queries); err != nil {
+		return err
+	}
+	return firstErr(q.nots)
+}
+
+func (q *AndQuery) Close() error {
+	err := closeAll(q.queries)
+	if notErr := closeAll(q.nots); err == nil {
+		err = notErr
+	}
+	return err
+}
+
+// Keeps the order of the queries, so the leading query stays the same
+func (q *AndQuery) Reset() {
+	resetAll(q.queries)
+	resetAll(q.nots)
+	if q.intersection != nil {
+		q.intersection.Reset()
+	}
+	q.docId = NOT_READY
+}
+
+// The clone has the same order and leading query, the intersection of
+// the bitmaps is shared
+func (q *AndQuery) Clone() Query {
+	c := &AndQuery{
+		queries: cloneAll(q.queries),
+		nots:    cloneAll(q.nots),
+		docId:   NOT_READY,
+		boost:   q.boost,
+	}
+	for i, s := range q.queries {
+		if s == q.leading {
+			c.leading = c.queries[i]
+		}
+	}
+	if q.intersection != nil {
+		c.intersection = q.intersection.Clone().(*RoaringTermQuery)
+		c.leading = c.intersection
+	}
+	return c
+}
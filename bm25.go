@@ -0,0 +1,83 @@
+package query
+
+import "math"
+
+// Document length lookup, used to normalize the term frequency by
+// the length of the document it appears in
+type Norms interface {
+	Length(docId int32) float32
+}
+
+// Document lengths indexed by document id, one byte per document, the
+// length is used as is, so longer documents have to be capped at 255
+type ByteNorms []uint8
+
+func (n ByteNorms) Length(docId int32) float32 {
+	if docId < 0 || int(docId) >= len(n) {
+		return 0
+	}
+	return float32(n[docId])
+}
+
+// Document lengths indexed by document id
+type Int32Norms []int32
+
+func (n Int32Norms) Length(docId int32) float32 {
+	if docId < 0 || int(docId) >= len(n) {
+		return 0
+	}
+	return float32(n[docId])
+}
+
+type BM25 struct {
+	k1        float32
+	b         float32
+	norms     Norms
+	avgLength float32
+}
+
+// Creates BM25 scorer, k1 controls the term frequency saturation and b
+// controls how much the document length normalizes the term frequency
+// (0 means no normalization, 1 means full normalization), the usual
+// values are k1: 1.2 and b: 0.75
+//
+// norms is the length of each document and avgLength is the average
+// length of the documents in the index, if norms is nil the length
+// normalization is disabled.
+//
+// BM25 implements Similarity, so it can be used by every term level
+// query via SetSimilarity(), Term(), TermTF() and FileTerm() also have
+// SetBM25()
+func NewBM25(k1, b float32, norms Norms, avgLength float32) *BM25 {
+	return &BM25{
+		k1:        k1,
+		b:         b,
+		norms:     norms,
+		avgLength: avgLength,
+	}
+}
+
+// idf is log(1 + (N - d + 0.5) / (d + 0.5))
+func (s *BM25) IDF(totalDocumentsInIndex, documentsMatching int) float32 {
+	N := float64(totalDocumentsInIndex)
+	d := float64(documentsMatching)
+	return float32(math.Log1p((N - d + 0.5) / (d + 0.5)))
+}
+
+// score is idf * (tf * (k1 + 1)) / (tf + k1 * (1 - b + b * length / avgLength))
+func (s *BM25) Score(idf float32, tf float32, docId int32) float32 {
+	norm := float32(1)
+	if s.norms != nil && s.avgLength > 0 {
+		norm = 1 - s.b + s.b*s.norms.Length(docId)/s.avgLength
+	}
+	return idf * (tf * (s.k1 + 1)) / (tf + s.k1*norm)
+}
+
+// the score is highest for empty documents, where the norm is 1 - b
+func (s *BM25) MaxScore(idf float32, tf float32) float32 {
+	norm := float32(1)
+	if s.norms != nil && s.avgLength > 0 {
+		norm = 1 - s.b
+	}
+	return idf * (tf * (s.k1 + 1)) / (tf + s.k1*norm)
+}
@@ -0,0 +1,70 @@
+package query
+
+import (
+	"math"
+	"testing"
+)
+
+func bm25(idf, tf, k1, b, length, avg float64) float32 {
+	return float32(idf * (tf * (k1 + 1)) / (tf + k1*(1-b+b*length/avg)))
+}
+
+func eqApprox(t *testing.T, a, b []float32) {
+	if len(a) != len(b) {
+		t.Fatalf("len(a) != len(b) ; len(a) = %d, len(b) = %d [%v %v]", len(a), len(b), a, b)
+	}
+
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-5 {
+			t.Fatalf("a[i] != b[i]; %v != %v", a, b)
+		}
+	}
+}
+
+func TestBM25(t *testing.T) {
+	norms := Int32Norms{10, 20, 5, 10, 40}
+	s := NewBM25(1.2, 0.75, norms, 17)
+	idf := math.Log1p((5 - 3 + 0.5) / (3 + 0.5))
+
+	scores := queryScores(Term(5, "x", []int32{0, 1, 2}).SetBM25(s))
+	eqApprox(t, []float32{
+		bm25(idf, 1, 1.2, 0.75, 10, 17),
+		bm25(idf, 1, 1.2, 0.75, 20, 17),
+		bm25(idf, 1, 1.2, 0.75, 5, 17),
+	}, scores)
+
+	// shorter documents score higher
+	if !(scores[2] > scores[0] && scores[0] > scores[1]) {
+		t.Fatalf("length normalization %v", scores)
+	}
+
+	eqF(t, scores, queryScores(CreateFileTerm(5, "x", []int32{0, 1, 2}).(*FileTermData).SetBM25(s)))
+
+	scores = queryScores(TermTF(5, 4, "x", []int32{0<<4 | 0, 1<<4 | 4, 2<<4 | 9}).SetBM25(s))
+	eqApprox(t, []float32{
+		bm25(idf, 1, 1.2, 0.75, 10, 17),
+		bm25(idf, 5, 1.2, 0.75, 20, 17),
+		bm25(idf, 10, 1.2, 0.75, 5, 17),
+	}, scores)
+
+	// saturation, never more than idf * (k1 + 1)
+	sat := queryScores(TermTF(5, 8, "x", []int32{0<<8 | 255}).SetBM25(NewBM25(1.2, 0, nil, 0)))[0]
+	if sat > float32(math.Log1p((5-1+0.5)/(1+0.5))*2.2) {
+		t.Fatalf("saturation %v", sat)
+	}
+
+	// no norms, b is ignored
+	eqF(t,
+		queryScores(Term(5, "x", []int32{0, 1}).SetBM25(NewBM25(1.2, 0, nil, 0))),
+		queryScores(Term(5, "x", []int32{0, 1}).SetBM25(NewBM25(1.2, 0.75, nil, 0))),
+	)
+
+	if ByteNorms([]uint8{3}).Length(0) != 3 || ByteNorms([]uint8{3}).Length(1) != 0 {
+		t.Fatal("byte norms")
+	}
+	if ByteNorms([]uint8{3}).Length(NOT_READY) != 0 || (Int32Norms{3}).Length(NOT_READY) != 0 {
+		t.Fatal("negative document")
+	}
+	// not positioned yet
+	Term(5, "x", []int32{0, 1}).SetBM25(NewBM25(1.2, 0.75, norms, 17)).Score()
+}
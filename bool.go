@@ -0,0 +1,386 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type BoolQuery struct {
+	must               []Query
+	should             []Query
+	mustNot            []Query
+	filter             []Query
+	minimumShouldMatch int
+	percent            bool
+	required           *AndQuery
+	resolvedMinimum    int
+	prepared           bool
+	docId              int32
+	boost              float32
+}
+
+// Creates bool query, similar to the elasticsearch bool query:
+//
+//	Bool().
+//		Must(Term(n, "name:new", ...)).
+//		Should(Term(n, "name:york", ...), Term(n, "name:city", ...)).
+//		Filter(Term(n, "country:us", ...)).
+//		MustNot(Term(n, "name:old", ...))
+//
+// must: all have to match and they contribute to the score
+// filter: all have to match but they dont contribute to the score
+// should: contribute to the score only when they match, if there are no
+// must or filter clauses at least one of them has to match, use
+// SetMinimumShouldMatch to require more
+// must_not: none of them can match
+//
+// WARNING: add all clauses before iterating
+func Bool() *BoolQuery {
+	return &BoolQuery{
+		docId: NOT_READY,
+		boost: 1,
+	}
+}
+
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	q.unprepare()
+	return q
+}
+
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	q.unprepare()
+	return q
+}
+
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	q.unprepare()
+	return q
+}
+
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	q.unprepare()
+	return q
+}
+
+// At least n should clauses have to match, negative n means all but n
+// of them, if n is bigger than the number of should clauses nothing
+// matches
+func (q *BoolQuery) SetMinimumShouldMatch(n int) *BoolQuery {
+	q.minimumShouldMatch = n
+	q.percent = false
+	q.unprepare()
+	return q
+}
+
+// At least p percent (rounded down) of the should clauses have to
+// match, negative p means all but p percent of them
+func (q *BoolQuery) SetMinimumShouldMatchPercent(p int) *BoolQuery {
+	q.minimumShouldMatch = p
+	q.percent = true
+	q.unprepare()
+	return q
+}
+
+// Adds must clause
+func (q *BoolQuery) AddSubQuery(sub Query) Query {
+	return q.Must(sub)
+}
+
+func (q *BoolQuery) resolveMinimumShouldMatch() int {
+	n := len(q.should)
+	m := q.minimumShouldMatch
+	if q.percent {
+		m = n * m / 100
+	}
+	if m < 0 {
+		m = n + m
+	}
+	if m < 0 {
+		m = 0
+	}
+	if len(q.must)+len(q.filter) == 0 && m == 0 {
+		m = 1
+	}
+	return m
+}
+
+func (q *BoolQuery) prepare() {
+	q.prepared = true
+	if len(q.must)+len(q.filter) > 0 {
+		required := []Query{}
+		required = append(required, q.must...)
+		required = append(required, q.filter...)
+		q.required = And(required...)
+	}
+	q.resolvedMinimum = q.resolveMinimumShouldMatch()
+}
+
+// the clauses changed, prepared again when iterating
+func (q *BoolQuery) unprepare() {
+	q.prepared = false
+	q.required = nil
+}
+
+func (q *BoolQuery) GetDocId() int32 {
+	return q.docId
+}
+
+// Does not prepare the query, so clauses can still be added after it
+// is passed to e.g. And()
+func (q *BoolQuery) Cost() int {
+	if q.required != nil {
+		return q.required.Cost()
+	}
+	if len(q.must)+len(q.filter) > 0 {
+		// same as the cost of And of them
+		min := -1
+		for _, queries := range [][]Query{q.must, q.filter} {
+			for _, sub := range queries {
+				if min < 0 || sub.Cost() < min {
+					min = sub.Cost()
+				}
+			}
+		}
+		return min
+	}
+	//XXX: optimistic, assume sets greatly overlap, which of course is not always true
+	max := 0
+	for _, sub := range q.should {
+		if max < sub.Cost() {
+			max = sub.Cost()
+		}
+	}
+	return max
+}
+
+func (q *BoolQuery) Score() float32 {
+	score := float32(0)
+	for _, s := range q.must {
+		score += s.Score()
+	}
+	for _, s := range q.should {
+		if s.GetDocId() == q.docId {
+			score += s.Score()
+		}
+	}
+	return score * q.boost
+}
+
+func (q *BoolQuery) UpperBound() float32 {
+	sum := float32(0)
+	for _, s := range q.must {
+		sum += s.UpperBound()
+	}
+	for _, s := range q.should {
+		sum += s.UpperBound()
+	}
+	return boostUpperBound(sum, q.boost)
+}
+
+// Returns the smallest document >= target that matches any should clause
+func (q *BoolQuery) nextShould(target int32) int32 {
+	newDoc := NO_MORE
+	for _, s := range q.should {
+		curDoc := s.GetDocId()
+		if curDoc < target {
+			curDoc = s.Advance(target)
+		}
+		if curDoc < newDoc {
+			newDoc = curDoc
+		}
+	}
+	return newDoc
+}
+
+// Returns how many should clauses match the candidate
+func (q *BoolQuery) countShould(candidate int32) int {
+	matching := 0
+	for _, s := range q.should {
+		curDoc := s.GetDocId()
+		if curDoc < candidate {
+			curDoc = s.Advance(candidate)
+		}
+		if curDoc == candidate {
+			matching++
+		}
+	}
+	return matching
+}
+
+func (q *BoolQuery) excluded(candidate int32) bool {
+	for _, s := range q.mustNot {
+		curDoc := s.GetDocId()
+		if curDoc < candidate {
+			curDoc = s.Advance(candidate)
+		}
+		if curDoc == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *BoolQuery) nextMatching(target int32) int32 {
+	if !q.prepared {
+		q.prepare()
+	}
+
+	if q.resolvedMinimum > len(q.should) || (q.required == nil && len(q.should) == 0) {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+
+	for {
+		var candidate int32
+		if q.required != nil {
+			candidate = q.required.Advance(target)
+		} else {
+			candidate = q.nextShould(target)
+		}
+
+		if candidate == NO_MORE {
+			break
+		}
+
+		if q.countShould(candidate) >= q.resolvedMinimum && !q.excluded(candidate) {
+			q.docId = candidate
+			return candidate
+		}
+		target = candidate + 1
+	}
+
+	q.docId = NO_MORE
+	return NO_MORE
+}
+
+func (q *BoolQuery) Advance(target int32) int32 {
+	return q.nextMatching(target)
+}
+
+func (q *BoolQuery) Next() int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.nextMatching(q.docId + 1)
+}
+
+func (q *BoolQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, "bool")
+	}
+
+	details := []*Explanation{}
+	for _, s := range q.must {
+		details = append(details, s.Explain())
+	}
+	for _, s := range q.filter {
+		details = append(details, explainMatch(0, "filter, not scored", s.Explain()))
+	}
+	matching := 0
+	for _, s := range q.should {
+		if s.GetDocId() == q.docId {
+			matching++
+			details = append(details, s.Explain())
+		} else {
+			details = append(details, explainNoMatch("should "+s.String()))
+		}
+	}
+	for _, s := range q.mustNot {
+		details = append(details, explainNoMatch("must not, excluded when matching "+s.String()))
+	}
+	if len(q.should) > 0 {
+		details = append(details, explainMatch(float32(matching), fmt.Sprintf("matching should clauses, minimum: %d", q.resolvedMinimum)))
+	}
+	return explainBoost(explainMatch(q.Score(), "bool, sum of:", details...), q.boost)
+}
+
+func (q *BoolQuery) String() string {
+	out := []string{}
+	for _, v := range q.must {
+		out = append(out, "+"+v.String())
+	}
+	for _, v := range q.filter {
+		out = append(out, "#"+v.String())
+	}
+	for _, v := range q.should {
+		out = append(out, v.String())
+	}
+	for _, v := range q.mustNot {
+		out = append(out, "-"+v.String())
+	}
+
+	s := "{" + strings.Join(out, " ") + "}"
+	if q.minimumShouldMatch != 0 {
+		if q.percent {
+			return fmt.Sprintf("%s~%d%%", s, q.minimumShouldMatch)
+		}
+		return fmt.Sprintf("%s~%d", s, q.minimumShouldMatch)
+	}
+	return s
+}
+
+func (q *BoolQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+func (q *BoolQuery) PayloadDecode(p Payload) {
+	p.Push()
+	defer p.Pop()
+
+	for _, s := range q.must {
+		s.PayloadDecode(p)
+	}
+	for _, s := range q.filter {
+		s.PayloadDecode(p)
+	}
+	for _, s := range q.should {
+		if s.GetDocId() == q.docId {
+			s.PayloadDecode(p)
+		}
+	}
+}
+
+func (q *BoolQuery) Err() error {
+	for _, queries := range [][]Query{q.must, q.filter, q.should, q.mustNot} {
+		if err := firstErr(queries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *BoolQuery) Close() error {
+	var first error
+	for _, queries := range [][]Query{q.must, q.filter, q.should, q.mustNot} {
+		if err := closeAll(queries); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (q *BoolQuery) Reset() {
+	for _, queries := range [][]Query{q.must, q.filter, q.should, q.mustNot} {
+		resetAll(queries)
+	}
+	q.unprepare()
+	q.docId = NOT_READY
+}
+
+func (q *BoolQuery) Clone() Query {
+	return &BoolQuery{
+		must:               cloneAll(q.must),
+		should:             cloneAll(q.should),
+		mustNot:            cloneAll(q.mustNot),
+		filter:             cloneAll(q.filter),
+		minimumShouldMatch: q.minimumShouldMatch,
+		percent:            q.percent,
+		docId:              NOT_READY,
+		boost:              q.boost,
+	}
+}
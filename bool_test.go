@@ -0,0 +1,84 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBool(t *testing.T) {
+	a := func() Query { return Term(10, "a", []int32{1, 2, 3, 4, 5, 6}) }
+	b := func() Query { return Term(10, "b", []int32{2, 4, 6, 8}) }
+	c := func() Query { return Term(10, "c", []int32{3, 4, 5, 8}) }
+	d := func() Query { return Term(10, "d", []int32{4, 6, 7, 8, 9}) }
+	x := func() Query { return Term(10, "x", []int32{5, 8}) }
+
+	eq(t, []int32{}, query(Bool()))
+	eq(t, []int32{2, 4, 6}, query(Bool().Must(a(), b())))
+	eq(t, []int32{2, 4, 6}, query(Bool().Must(a()).Filter(b())))
+	eq(t, []int32{1, 2, 3, 4, 5, 6, 8}, query(Bool().Should(a(), b())))
+	eq(t, []int32{1, 2, 3, 4, 6}, query(Bool().Should(a(), b()).MustNot(x())))
+	eq(t, []int32{}, query(Bool().MustNot(x())))
+
+	// should is optional when there are required clauses
+	eq(t, []int32{1, 2, 3, 4, 5, 6}, query(Bool().Must(a()).Should(b(), c())))
+	eq(t, []int32{2, 3, 4, 5, 6}, query(Bool().Must(a()).Should(b(), c()).SetMinimumShouldMatch(1)))
+	eq(t, []int32{4}, query(Bool().Must(a()).Should(b(), c()).SetMinimumShouldMatch(2)))
+
+	// at least 2 of 4
+	eq(t, []int32{2, 3, 4, 5, 6, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(2)))
+	eq(t, []int32{4, 6, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(-1)))
+	eq(t, []int32{4, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(-1).MustNot(Term(10, "y", []int32{6}))))
+	eq(t, []int32{4, 6, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatchPercent(75)))
+	eq(t, []int32{4, 6, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatchPercent(-25)))
+	eq(t, []int32{2, 3, 4, 5, 6, 8}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatchPercent(50)))
+	eq(t, []int32{4}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(4)))
+	eq(t, []int32{}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(5)))
+	eq(t, []int32{1, 2, 3, 4, 5, 6, 7, 8, 9}, query(Bool().Should(a(), b(), c(), d()).SetMinimumShouldMatch(0)))
+
+	// filter does not contribute to the score, should only when it matches
+	eqF(t, []float32{
+		computeIDF(10, 6),
+		computeIDF(10, 6) + computeIDF(10, 5),
+		computeIDF(10, 6) + computeIDF(10, 5),
+	}, queryScores(Bool().Must(a()).Filter(b()).Should(d())))
+
+	eqApprox(t,
+		queryScores(And(a(), Or(b(), c()))),
+		queryScores(Bool().Must(a()).Should(b(), c()).SetMinimumShouldMatch(1)),
+	)
+
+	eq(t, []int32{4, 6}, query(And(
+		Bool().Must(a()).Filter(b()),
+		Or(c(), d()),
+	)))
+
+	q := Bool().Must(a()).Should(b()).Filter(c()).MustNot(x())
+	if q.Advance(4) != 4 {
+		t.Fatal("advance")
+	}
+	e := q.Explain().String()
+	if !strings.Contains(e, "filter, not scored") || !strings.Contains(e, "matching should clauses, minimum: 0") {
+		t.Fatal(e)
+	}
+	if q.Score() != q.Explain().Value || q.Score() > q.UpperBound() {
+		t.Fatal("score")
+	}
+	if q.Next() != NO_MORE {
+		t.Fatal("next")
+	}
+
+	// clauses added after the query is passed to And
+	late := Bool().Should(a())
+	and := And(late, d())
+	late.Must(b()).MustNot(x())
+	eq(t, []int32{4, 6}, query(and))
+	late = Bool().Should(a(), c())
+	and = And(late, a())
+	late.SetMinimumShouldMatch(2)
+	eq(t, []int32{3, 4, 5}, query(and))
+
+	s := Bool().Must(a()).Should(b()).Filter(c()).MustNot(x()).SetMinimumShouldMatchPercent(50).String()
+	if s != "{+a/0.98 #c/1.25 b/1.25 -x/1.79}~50%" {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,121 @@
+package query
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestResetClone(t *testing.T) {
+	rand.Seed(0)
+	// the files have to stay around, closed queries open them again
+	dir, err := ioutil.TempDir("", "tt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := path.Join(dir, "postings")
+	if err := AppendFileNameTerm(fn, []int32{1, 5, 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := newPositionalIndex("new york city new york new")
+	encoded, _ := EncodePostings([]int32{1, 5, 9, 300})
+	price := NewDocValues("price").SetInt64(1, 5).SetInt64(5, 7).SetInt64(9, 100)
+	a, b := uniquePostingsList(2000), uniquePostingsList(2000)
+
+	queries := []Query{
+		Term(10, "x", []int32{1, 2, 3}),
+		TermTF(10, 2, "x", []int32{1<<2 | 1, 2 << 2}),
+		PayloadTerm(10, "x", []int32{1, 2}, []byte{1, 2}),
+		CompressedTerm(10, "x", encoded),
+		RoaringTerm(10, "x", NewBitmap(a)),
+		FileTerm(10, fn),
+		MmapFileTerm(10, fn),
+		FileTerm(10, "/tmp/must_not_exist_some_random_file"),
+		DocRange(3, 8),
+		MatchAll(4),
+		MatchNone(),
+		NumericRange(price, 5, 10),
+		Constant(2, Term(10, "x", []int32{1, 2, 3})),
+		Or(Term(10, "x", a), Term(10, "y", b)).SetBoost(2),
+		DisMax(0.5, Term(10, "x", a), Term(10, "y", b)),
+		And(Term(10, "x", a), Or(Term(10, "y", b), Term(10, "z", a[:100]))).AddNot(Term(10, "w", b[:100])),
+		And(RoaringTerm(10, "x", NewBitmap(a)), RoaringTerm(10, "y", NewBitmap(b)), Term(10, "z", a)),
+		WAND(Term(10, "x", a), Term(10, "y", b)),
+		MaxScore(Term(10, "x", a), Term(10, "y", b)),
+		Bool().Must(Term(10, "x", a)).Should(Term(10, "y", b)).MustNot(Term(10, "z", b[:10])).SetMinimumShouldMatch(1),
+		Phrase(idx.terms("new york")...),
+		Near(1, false, idx.terms("york new")...),
+	}
+
+	// in doc id order, so the first hit is the first Next()
+	hits := func(q Query) []Hit {
+		out := []Hit{}
+		for q.Next() != NO_MORE {
+			out = append(out, Hit{DocId: q.GetDocId(), Score: q.Score()})
+		}
+		return out
+	}
+
+	for _, q := range queries {
+		expected := hits(q)
+		q.Reset()
+		eqHits(t, expected, hits(q))
+
+		q.Reset()
+		c := q.Clone()
+		if c.String() != q.String() {
+			t.Fatalf("%s != %s", c.String(), q.String())
+		}
+
+		// the clone iterates independently
+		if len(expected) > 0 {
+			if q.Next() != expected[0].DocId {
+				t.Fatalf("%s: next", q.String())
+			}
+		}
+		eqHits(t, expected, hits(c))
+		rest := hits(q)
+		if len(expected) > 0 {
+			eqHits(t, expected[1:], rest)
+		}
+
+		// clone of exhausted query starts from the beginning
+		eqHits(t, expected, hits(q.Clone()))
+
+		// reset in the middle
+		q.Reset()
+		q.Next()
+		q.Reset()
+		eqHits(t, expected, hits(q))
+
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	// top k with pruning can be run again
+	w := WAND(Term(10000, "x", a), Term(10000, "y", b), TermTF(10000, 4, "z", termsWithFrequencies(3, a[:50])))
+	expected, _ := TopK(w, 10)
+	for i := 0; i < 3; i++ {
+		w.Reset()
+		hits, _ := TopK(w, 10)
+		eqHits(t, expected, hits)
+		hits, _ = TopK(w.Clone(), 10)
+		eqHits(t, expected, hits)
+	}
+
+	// clones share the mapping, it is unmapped when the last is closed
+	m := MmapFileTerm(10, fn)
+	m.Next()
+	c := m.Clone().(*MmapFileTermData)
+	m.Close()
+	eq(t, []int32{1, 5, 7}, query(c))
+	if m.mapping.data != nil {
+		t.Fatal("still mapped")
+	}
+}
@@ -0,0 +1,146 @@
+package query
+
+import (
+	"container/heap"
+	"sort"
+)
+
+type Hit struct {
+	DocId int32
+	Score float32
+}
+
+// Receives every document that matches the query
+type Collector interface {
+	Collect(docId int32, score float32)
+}
+
+// Implemented by collectors that know the minimum score a document
+// needs to be collected, e.g. the score of the worst hit in a full
+// top k heap
+type CompetitiveCollector interface {
+	Collector
+	MinCompetitiveScore() (float32, bool)
+}
+
+// Iterates the query and passes each matching document and its score
+// to the collector
+//
+// If the query is a PruningQuery (e.g. WAND) and the collector is a
+// CompetitiveCollector (e.g. TopKCollector), the query is told to skip
+// documents that can not be collected
+//
+// Check q.Err() after the search, on error (e.g. failed read of
+// FileTerm) the iteration stops early
+func Search(q Query, c Collector) {
+	pruning, canPrune := q.(PruningQuery)
+	competitive, knowsMin := c.(CompetitiveCollector)
+	if !canPrune || !knowsMin {
+		for q.Next() != NO_MORE {
+			c.Collect(q.GetDocId(), q.Score())
+		}
+		return
+	}
+
+	for q.Next() != NO_MORE {
+		c.Collect(q.GetDocId(), q.Score())
+		if min, ok := competitive.MinCompetitiveScore(); ok {
+			pruning.SetMinCompetitiveScore(min)
+		}
+	}
+}
+
+// hits with higher score win, on equal score the lower document id wins
+func hitBefore(a, b Hit) bool {
+	if a.Score == b.Score {
+		return a.DocId < b.DocId
+	}
+	return a.Score > b.Score
+}
+
+// min heap, the worst hit is on top
+type hitHeap []Hit
+
+func (h hitHeap) Len() int            { return len(h) }
+func (h hitHeap) Less(i, j int) bool  { return hitBefore(h[j], h[i]) }
+func (h hitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hitHeap) Push(x interface{}) { *h = append(*h, x.(Hit)) }
+func (h *hitHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+type TopKCollector struct {
+	k     int
+	hits  hitHeap
+	total int
+}
+
+// Creates collector that keeps the best k hits in a bounded min heap,
+// ties are broken by document id (lower wins), so the result is
+// stable regardless of the order in which documents are collected,
+// the heap grows with the hits, so big k (e.g. all documents) does not
+// allocate upfront
+func NewTopKCollector(k int) *TopKCollector {
+	if k < 0 {
+		k = 0
+	}
+	return &TopKCollector{
+		k:    k,
+		hits: hitHeap{},
+	}
+}
+
+func (c *TopKCollector) Collect(docId int32, score float32) {
+	c.total++
+	hit := Hit{DocId: docId, Score: score}
+	if len(c.hits) < c.k {
+		heap.Push(&c.hits, hit)
+		return
+	}
+
+	if c.k > 0 && hitBefore(hit, c.hits[0]) {
+		c.hits[0] = hit
+		heap.Fix(&c.hits, 0)
+	}
+}
+
+// Once the heap is full only documents that beat the worst hit can be
+// collected, documents are collected in increasing order so equal
+// score is not enough
+func (c *TopKCollector) MinCompetitiveScore() (float32, bool) {
+	if c.k == 0 || len(c.hits) < c.k {
+		return 0, false
+	}
+	return c.hits[0].Score, true
+}
+
+// Returns how many documents were collected
+func (c *TopKCollector) TotalHits() int {
+	return c.total
+}
+
+// Returns the best hits sorted by score (highest first)
+func (c *TopKCollector) Hits() []Hit {
+	out := make([]Hit, len(c.hits))
+	copy(out, c.hits)
+	sort.Slice(out, func(i, j int) bool {
+		return hitBefore(out[i], out[j])
+	})
+	return out
+}
+
+// Returns the best k hits sorted by score (highest first) and the
+// total number of matching documents, for pagination ask for
+// offset+limit hits and skip the first offset
+//
+// When the query prunes non competitive documents (see WAND) the
+// total is a lower bound
+func TopK(q Query, k int) ([]Hit, int) {
+	c := NewTopKCollector(k)
+	Search(q, c)
+	return c.Hits(), c.TotalHits()
+}
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func allHits(q Query) []Hit {
+	out := []Hit{}
+	for q.Next() != NO_MORE {
+		out = append(out, Hit{DocId: q.GetDocId(), Score: q.Score()})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+func eqHits(t *testing.T, a, b []Hit) {
+	if len(a) != len(b) {
+		t.Fatalf("len(a) != len(b) ; len(a) = %d, len(b) = %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("a[%d] != b[%d]; %v != %v", i, i, a[i], b[i])
+		}
+	}
+}
+
+func uniquePostingsList(n int) []int32 {
+	postings := postingsList(n)
+	out := []int32{}
+	for i, p := range postings {
+		if i == 0 || postings[i-1] != p {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func randomTermTF(n int, t string) *TermTFQuery {
+	postings := postingsList(n)
+	out := []int32{}
+	for i, p := range postings {
+		if i > 0 && postings[i-1]>>4 == p>>4 {
+			continue
+		}
+		out = append(out, (p>>4)<<4|rand.Int31n(16))
+	}
+	return TermTF(1000000, 4, t, out)
+}
+
+func TestTopK(t *testing.T) {
+	hits, total := TopK(Term(10, "x", []int32{}), 10)
+	if len(hits) != 0 || total != 0 {
+		t.Fatal("empty")
+	}
+
+	// all hits, nothing is allocated upfront
+	hits, total = TopK(Term(10, "x", []int32{1, 2, 3}), math.MaxInt32)
+	if len(hits) != 3 || total != 3 {
+		t.Fatal("all")
+	}
+
+	hits, total = TopK(Or(
+		Term(10, "a", []int32{1, 2, 3, 4}),
+		Term(10, "b", []int32{3, 5}),
+	), 3)
+	if total != 5 {
+		t.Fatalf("total %d", total)
+	}
+	// ties are broken by document id
+	eqHits(t, []Hit{
+		{DocId: 3, Score: computeIDF(10, 4) + computeIDF(10, 2)},
+		{DocId: 5, Score: computeIDF(10, 2)},
+		{DocId: 1, Score: computeIDF(10, 4)},
+	}, hits)
+
+	hits, total = TopK(Term(10, "a", []int32{1, 2, 3, 4}), 0)
+	if len(hits) != 0 || total != 4 {
+		t.Fatal("count only")
+	}
+
+	for _, k := range []int{1, 10, 100, 10000} {
+		q := func() Query {
+			rand.Seed(int64(k))
+			return Or(randomTermTF(1000, "a"), randomTermTF(5000, "b"), randomTermTF(100, "c"))
+		}
+		expected := allHits(q())
+		hits, total := TopK(q(), k)
+		if total != len(expected) {
+			t.Fatalf("total %d != %d", total, len(expected))
+		}
+		if k < len(expected) {
+			expected = expected[:k]
+		}
+		eqHits(t, expected, hits)
+	}
+}
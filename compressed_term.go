@@ -0,0 +1,352 @@
+package query
+
+import (
+	"fmt"
+)
+
+type CompressedTermQuery struct {
+	docId      int32
+	data       []byte
+	n          int
+	blocks     []postingsBlock
+	blockIndex int
+	decoded    []int32
+	cursor     int
+	term       string
+	idf        float32
+	boost      float32
+	totalDocs  int
+	similarity Similarity
+
+	// term frequencies encoded with encodeInts, nil if all are 1, the
+	// block of frequencies is decoded only when the score is needed
+	freqs        []byte
+	freqBlocks   []intsBlock
+	freqIndex    int
+	decodedFreqs []int32
+	maxTF        float32
+
+	// the mapped segment the data points to, nil if it is not mapped
+	mapping *mapping
+	closed  bool
+	err     error
+}
+
+// Same as Term() but the postings are compressed with EncodePostings,
+// only the skip table is decoded upfront, the blocks of
+// POSTINGS_BLOCK_SIZE documents are decoded when the iterator reaches
+// them and the blocks that Advance() skips are never decoded
+//
+// panics if the skip table is corrupt, use OpenCompressedTerm() to get
+// the error instead
+// use Reset() to iterate it again, Clone() for another goroutine (see Query)
+func CompressedTerm(totalDocumentsInIndex int, t string, data []byte) *CompressedTermQuery {
+	q, err := OpenCompressedTerm(totalDocumentsInIndex, t, data)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Same as CompressedTerm() but returns the error if the skip table is
+// corrupt, corrupt blocks found while iterating stop the iteration and
+// are returned by Err()
+func OpenCompressedTerm(totalDocumentsInIndex int, t string, data []byte) (*CompressedTermQuery, error) {
+	return compressedTerm(totalDocumentsInIndex, t, data, nil)
+}
+
+// freqs are the term frequencies of the postings encoded with
+// encodeInts, or nil if all of them are 1
+func compressedTerm(totalDocumentsInIndex int, t string, data []byte, freqs []byte) (*CompressedTermQuery, error) {
+	n, blocks, err := decodePostingsHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	q := &CompressedTermQuery{
+		docId:      NOT_READY,
+		data:       data,
+		n:          n,
+		blocks:     blocks,
+		blockIndex: -1,
+		decoded:    make([]int32, 0, POSTINGS_BLOCK_SIZE),
+		term:       t,
+		idf:        computeIDF(totalDocumentsInIndex, n),
+		boost:      1,
+		totalDocs:  totalDocumentsInIndex,
+		similarity: TFIDF{},
+		freqIndex:  -1,
+		maxTF:      1,
+	}
+	if n == 0 {
+		q.idf = 0
+	}
+	if len(freqs) > 0 {
+		count, freqBlocks, err := decodeIntsHeader(freqs)
+		if err != nil {
+			return nil, err
+		}
+		if count != n {
+			return nil, errCorruptPostings
+		}
+		q.freqs = freqs
+		q.freqBlocks = freqBlocks
+		q.decodedFreqs = make([]int32, 0, POSTINGS_BLOCK_SIZE)
+		q.maxTF = 0
+		for _, b := range freqBlocks {
+			if float32(b.max) > q.maxTF {
+				q.maxTF = float32(b.max)
+			}
+		}
+	}
+	return q, nil
+}
+
+func (t *CompressedTermQuery) GetDocId() int32 {
+	return t.docId
+}
+
+func (t *CompressedTermQuery) Cost() int {
+	if t.blockIndex < 0 {
+		return t.n
+	}
+	if t.blockIndex >= len(t.blocks) {
+		return 0
+	}
+	return t.n - t.blockIndex*POSTINGS_BLOCK_SIZE - t.cursor
+}
+
+func (t *CompressedTermQuery) String() string {
+	return fmt.Sprintf("%s/%.2f", t.term, t.idf)
+}
+
+// Returns the term frequency of the current document, decodes its
+// block of frequencies if needed
+func (t *CompressedTermQuery) freq() float32 {
+	if t.freqs == nil || t.docId == NOT_READY || t.docId == NO_MORE {
+		return 1
+	}
+	if t.freqIndex != t.blockIndex {
+		decoded, err := decodeIntsBlock(t.freqs, t.freqBlocks[t.blockIndex], t.decodedFreqs)
+		if err != nil {
+			// the score is wrong, so stop at the next document
+			t.fail(err)
+			return 1
+		}
+		t.decodedFreqs = decoded
+		t.freqIndex = t.blockIndex
+	}
+	return float32(t.decodedFreqs[t.cursor])
+}
+
+// records the first error, the iteration stops
+func (t *CompressedTermQuery) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *CompressedTermQuery) Score() float32 {
+	return t.similarity.Score(t.idf, t.freq(), t.docId) * t.boost
+}
+
+// Score with the given similarity instead of TFIDF, the term
+// frequency is 1 unless the query comes from a segment with stored
+// frequencies (see SegmentReader)
+func (t *CompressedTermQuery) SetSimilarity(s Similarity) *CompressedTermQuery {
+	t.similarity = s
+	if t.n > 0 {
+		t.idf = s.IDF(t.totalDocs, t.n)
+	}
+	return t
+}
+
+func (t *CompressedTermQuery) UpperBound() float32 {
+	if t.n == 0 {
+		return 0
+	}
+	return boostUpperBound(t.similarity.MaxScore(t.idf, t.maxTF), t.boost)
+}
+
+// The max term frequency of each block is in the skip table of the
+// frequencies, without them all blocks have the same upper bound
+func (t *CompressedTermQuery) BlockUpperBound(target int32) (int32, float32) {
+	found := t.searchBlock(target)
+	if found == len(t.blocks) {
+		return NO_MORE, 0
+	}
+	if t.freqs == nil {
+		return t.blocks[found].maxDoc, t.UpperBound()
+	}
+	tf := float32(t.freqBlocks[found].max)
+	return t.blocks[found].maxDoc, boostUpperBound(t.similarity.MaxScore(t.idf, tf), t.boost)
+}
+
+func (t *CompressedTermQuery) Explain() *Explanation {
+	if t.docId == NOT_READY || t.docId == NO_MORE {
+		return explainNotReady(t.docId, "term "+t.term)
+	}
+	return explainTerm(t.Score(), t.term, t.similarity, t.idf, t.totalDocs, t.n, t.freq(), t.boost)
+}
+
+// Returns the index of the first block that can contain target,
+// starting from the current block, len(t.blocks) if none
+func (t *CompressedTermQuery) searchBlock(target int32) int {
+	start := t.blockIndex
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(t.blocks); i++ {
+		if target <= t.blocks[i].maxDoc {
+			return i
+		}
+		if i-start >= 8 {
+			// far away, binary search the rest
+			lo, hi := i+1, len(t.blocks)
+			for lo < hi {
+				mid := lo + (hi-lo)/2
+				if target <= t.blocks[mid].maxDoc {
+					hi = mid
+				} else {
+					lo = mid + 1
+				}
+			}
+			return lo
+		}
+	}
+	return len(t.blocks)
+}
+
+// decodes the block, on corrupt block records the error and leaves
+// nothing decoded, so the iteration ends
+func (t *CompressedTermQuery) decodeBlock(i int) {
+	t.blockIndex = i
+	t.cursor = 0
+	t.decoded = t.decoded[:0]
+	if i >= len(t.blocks) {
+		return
+	}
+	prev := int32(-1)
+	if i > 0 {
+		prev = t.blocks[i-1].maxDoc
+	}
+	decoded, err := decodePostingsBlock(t.data, t.blocks[i], prev, t.decoded)
+	if err != nil {
+		t.fail(err)
+		t.blockIndex = len(t.blocks)
+		return
+	}
+	t.decoded = decoded
+}
+
+func (t *CompressedTermQuery) Advance(target int32) int32 {
+	if t.docId == NO_MORE || t.err != nil || t.closed {
+		t.docId = NO_MORE
+		return NO_MORE
+	}
+
+	found := t.searchBlock(target)
+	if found == len(t.blocks) {
+		t.blockIndex = found
+		t.docId = NO_MORE
+		return NO_MORE
+	}
+	if found != t.blockIndex {
+		t.decodeBlock(found)
+	}
+
+	for i := t.cursor; i < len(t.decoded); i++ {
+		if t.decoded[i] >= target {
+			t.cursor = i
+			t.docId = t.decoded[i]
+			return t.docId
+		}
+	}
+	// corrupt block (see Err()), otherwise the block max is >= target
+	t.docId = NO_MORE
+	return NO_MORE
+}
+
+func (t *CompressedTermQuery) Next() int32 {
+	if t.docId == NO_MORE || t.err != nil || t.closed {
+		t.docId = NO_MORE
+		return NO_MORE
+	}
+	if t.blockIndex < 0 {
+		t.decodeBlock(0)
+	} else {
+		t.cursor++
+		if t.cursor >= len(t.decoded) {
+			t.decodeBlock(t.blockIndex + 1)
+		}
+	}
+
+	if t.cursor >= len(t.decoded) {
+		t.docId = NO_MORE
+	} else {
+		t.docId = t.decoded[t.cursor]
+	}
+	return t.docId
+}
+
+func (t *CompressedTermQuery) SetBoost(b float32) Query {
+	t.boost = b
+	return t
+}
+
+func (t *CompressedTermQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (t *CompressedTermQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (t *CompressedTermQuery) Err() error {
+	return t.err
+}
+
+// Releases the mapped segment (see SegmentReader.Term()), it is
+// unmapped when the reader and all the queries are closed
+func (t *CompressedTermQuery) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	t.docId = NO_MORE
+	if t.mapping != nil {
+		return t.mapping.release()
+	}
+	return nil
+}
+
+// If the query was already closed it takes the mapped segment again,
+// ErrSegmentClosed if it is already unmapped
+func (t *CompressedTermQuery) Reset() {
+	t.docId = NOT_READY
+	t.blockIndex = -1
+	t.cursor = 0
+	t.decoded = t.decoded[:0]
+	t.freqIndex = -1
+	t.err = nil
+	if t.closed {
+		if t.mapping != nil && !t.mapping.acquire() {
+			t.err = ErrSegmentClosed
+			t.docId = NO_MORE
+			return
+		}
+		t.closed = false
+	}
+}
+
+// The compressed data and the skip table are shared, the clone takes
+// its own reference of the mapped segment
+func (t *CompressedTermQuery) Clone() Query {
+	c := *t
+	c.decoded = make([]int32, 0, POSTINGS_BLOCK_SIZE)
+	if c.freqs != nil {
+		c.decodedFreqs = make([]int32, 0, POSTINGS_BLOCK_SIZE)
+	}
+	c.closed = c.mapping != nil
+	c.Reset()
+	return &c
+}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+type sharedIndex struct {
+	dict    *TermDictionary
+	tf      *TermTFData
+	segment *SegmentReader
+	bitmap  *Bitmap
+	price   *DocValues
+	filters *FilterCache
+}
+
+func newSharedIndex(t *testing.T, n int) *sharedIndex {
+	r := rand.New(rand.NewSource(0))
+	terms := map[string][]int32{}
+	w := NewIndexWriter()
+	price := NewDocValues("price")
+	for docId := int32(0); docId < int32(n); docId++ {
+		for _, term := range []string{"a", "b", "c", "d", "e"} {
+			if r.Intn(3) == 0 {
+				terms[term] = append(terms[term], docId)
+				if err := w.Add(term, docId, int32(1+r.Intn(5))); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		price.SetInt64(docId, int64(r.Intn(1000)))
+	}
+	tf := []int32{}
+	for _, docId := range terms["a"] {
+		tf = append(tf, docId<<2|int32(r.Intn(4)))
+	}
+
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := NewSegmentReader(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &sharedIndex{
+		dict:    NewTermDictionary(n, terms),
+		tf:      NewTermTFData(n, 2, "a", tf),
+		segment: segment,
+		bitmap:  NewBitmap(terms["c"]),
+		price:   price.Freeze(),
+		filters: NewFilterCache(2),
+	}
+}
+
+// every query creates its own iterators from the shared data
+func (idx *sharedIndex) queries(n int) []Query {
+	filter := func(term string) Query {
+		return idx.filters.Filter(term, func() Query {
+			return idx.dict.Term(term)
+		})
+	}
+	segment := func(term string) Query {
+		q, err := idx.segment.Term(term)
+		if err != nil {
+			panic(err)
+		}
+		return q
+	}
+	prefix, _ := idx.dict.Prefix("")
+	fuzzy, _ := idx.dict.Fuzzy("x", 1, 0)
+	return []Query{
+		And(idx.dict.Term("a"), idx.dict.Term("b")),
+		prefix,
+		fuzzy,
+		WAND(idx.tf.Query(), idx.dict.Term("b"), segment("c")),
+		MaxScore(idx.tf.Query(), segment("d"), segment("e")),
+		And(RoaringTerm(n, "c", idx.bitmap), RoaringTerm(n, "c", idx.bitmap), idx.dict.Term("d")),
+		Bool().
+			Must(NumericRange(idx.price, 100, 500)).
+			Should(idx.tf.Query()).
+			Filter(filter("b"), filter("e")),
+		AndNot(filter("d"), segment("a"), filter("c")),
+	}
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	n := 20000
+	expected := [][]Hit{}
+	for _, q := range newSharedIndex(t, n).queries(n) {
+		hits, _ := TopK(q, 20)
+		expected = append(expected, hits)
+	}
+
+	// fresh index, so the lazily built parts (block max term
+	// frequencies, cached filters) are built concurrently, run with
+	// -race
+	idx := newSharedIndex(t, n)
+	var wg sync.WaitGroup
+	errors := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				for j, q := range idx.queries(n) {
+					hits, _ := TopK(q, 20)
+					if fmt.Sprint(hits) != fmt.Sprint(expected[j]) {
+						errors <- fmt.Errorf("%s: %v != %v", q.String(), hits, expected[j])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentClones(t *testing.T) {
+	n := 20000
+	idx := newSharedIndex(t, n)
+	plan := idx.queries(n)
+
+	expected := [][]Hit{}
+	for _, q := range plan {
+		hits, _ := TopK(q.Clone(), 20)
+		expected = append(expected, hits)
+	}
+
+	var wg sync.WaitGroup
+	errors := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				for j, q := range plan {
+					hits, _ := TopK(q.Clone(), 20)
+					if fmt.Sprint(hits) != fmt.Sprint(expected[j]) {
+						errors <- fmt.Errorf("%s: %v != %v", q.String(), hits, expected[j])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Fatal(err)
+	}
+}
@@ -26,6 +26,18 @@ func (q *ConstantQuery) Score() float32 {
 	return q.boost
 }
 
+func (q *ConstantQuery) UpperBound() float32 {
+	return q.boost
+}
+
+func (q *ConstantQuery) Explain() *Explanation {
+	sub := q.query.Explain()
+	if !sub.Match {
+		return explainNoMatch("constant", sub)
+	}
+	return explainMatch(q.boost, "constant", sub)
+}
+
 func (q *ConstantQuery) Advance(target int32) int32 {
 	return q.query.Advance(target)
 }
@@ -50,3 +62,19 @@ func (q *ConstantQuery) PayloadDecode(p Payload) {
 func (q *ConstantQuery) AddSubQuery(Query) Query {
 	panic("unsupported")
 }
+
+func (q *ConstantQuery) Err() error {
+	return q.query.Err()
+}
+
+func (q *ConstantQuery) Close() error {
+	return q.query.Close()
+}
+
+func (q *ConstantQuery) Reset() {
+	q.query.Reset()
+}
+
+func (q *ConstantQuery) Clone() Query {
+	return Constant(q.boost, q.query.Clone())
+}
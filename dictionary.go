@@ -0,0 +1,174 @@
+package query
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Prefix, wildcard, regexp and fuzzy queries keep only the
+// MAX_EXPANSIONS terms with most documents
+var MAX_EXPANSIONS = 1024
+
+// Sorted dictionary of terms and their postings, the terms are kept in
+// a sorted slice, so prefix enumeration is binary search and a scan of
+// the matching range. The skip index of every term is built upfront
+// and it is never modified afterwards, so it is safe to create queries
+// from many goroutines
+type TermDictionary struct {
+	totalDocs int
+	terms     []string
+	data      []*TermData
+}
+
+// Creates dictionary from term -> postings map, the postings must be
+// sorted, totalDocs is the number of documents in the index and is
+// used for the idf of the term queries
+func NewTermDictionary(totalDocs int, terms map[string][]int32) *TermDictionary {
+	d := &TermDictionary{
+		totalDocs: totalDocs,
+		terms:     make([]string, 0, len(terms)),
+		data:      make([]*TermData, 0, len(terms)),
+	}
+	for t := range terms {
+		d.terms = append(d.terms, t)
+	}
+	sort.Strings(d.terms)
+	for _, t := range d.terms {
+		d.data = append(d.data, NewTermData(totalDocs, t, terms[t]))
+	}
+	return d
+}
+
+// Returns the number of terms in the dictionary
+func (d *TermDictionary) Len() int {
+	return len(d.terms)
+}
+
+func (d *TermDictionary) find(t string) int {
+	return sort.SearchStrings(d.terms, t)
+}
+
+// Returns the postings of the term, nil if it is not in the dictionary
+func (d *TermDictionary) Postings(t string) []int32 {
+	i := d.find(t)
+	if i < len(d.terms) && d.terms[i] == t {
+		return d.data[i].postings
+	}
+	return nil
+}
+
+// Creates term query, if the term is not in the dictionary it matches
+// nothing
+func (d *TermDictionary) Term(t string) *TermQuery {
+	i := d.find(t)
+	if i < len(d.terms) && d.terms[i] == t {
+		return d.data[i].Query()
+	}
+	return Term(d.totalDocs, t, []int32{})
+}
+
+// returns the range of terms starting with prefix
+func (d *TermDictionary) prefixRange(prefix string) (int, int) {
+	from := d.find(prefix)
+	to := from + sort.Search(len(d.terms)-from, func(i int) bool {
+		return !strings.HasPrefix(d.terms[from+i], prefix)
+	})
+	return from, to
+}
+
+// calls match for every term starting with prefix and creates Or of
+// term queries for the matching ones, keeping the MAX_EXPANSIONS terms
+// with most documents, in dictionary order, returns the query and the
+// number of matching terms
+func (d *TermDictionary) expand(prefix string, match func(string) bool) (*OrQuery, int) {
+	from, to := d.prefixRange(prefix)
+	matching := []int{}
+	for i := from; i < to; i++ {
+		if match(d.terms[i]) {
+			matching = append(matching, i)
+		}
+	}
+
+	total := len(matching)
+	if len(matching) > MAX_EXPANSIONS {
+		sort.SliceStable(matching, func(i, j int) bool {
+			return d.data[matching[i]].Len() > d.data[matching[j]].Len()
+		})
+		matching = matching[:MAX_EXPANSIONS]
+		sort.Ints(matching)
+	}
+
+	out := make([]Query, len(matching))
+	for i, idx := range matching {
+		out[i] = d.data[idx].Query()
+	}
+	return Or(out...), total
+}
+
+// Returns Or of term queries for all terms starting with prefix and
+// the number of matching terms, if it is more than MAX_EXPANSIONS the
+// query has only the MAX_EXPANSIONS terms with most documents, e.g.
+//
+//	q, n := dict.Prefix("name:amst")
+//	if n > MAX_EXPANSIONS {
+//		// too broad, ask for more characters
+//	}
+func (d *TermDictionary) Prefix(prefix string) (*OrQuery, int) {
+	return d.expand(prefix, func(string) bool { return true })
+}
+
+// Returns Or of term queries for all terms matching the pattern and
+// the number of matching terms (see Prefix), '*' matches any sequence
+// of characters and '?' matches exactly one character, e.g.
+// "name:am*dam"
+func (d *TermDictionary) Wildcard(pattern string) (*OrQuery, int) {
+	prefix := pattern
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	p := []rune(pattern)
+	return d.expand(prefix, func(t string) bool {
+		return wildcardMatch(p, []rune(t))
+	})
+}
+
+// Returns Or of term queries for all terms matching the regular
+// expression and the number of matching terms (see Prefix), it has to
+// match the whole term, e.g. "name:am.*dam" matches "name:amsterdam"
+// but "name:am" does not
+func (d *TermDictionary) Regexp(expr string) (*OrQuery, int, error) {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, 0, err
+	}
+	prefix, _ := re.LiteralPrefix()
+	q, n := d.expand(prefix, re.MatchString)
+	return q, n, nil
+}
+
+func wildcardMatch(pattern, s []rune) bool {
+	p, i := 0, 0
+	star, backtrack := -1, 0
+	for i < len(s) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]) {
+			p++
+			i++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			star = p
+			backtrack = i
+			p++
+		} else if star >= 0 {
+			// let the last star consume one more character
+			p = star + 1
+			backtrack++
+			i = backtrack
+		} else {
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"testing"
+)
+
+func newTestDictionary() *TermDictionary {
+	return NewTermDictionary(10, map[string][]int32{
+		"name:amsterdam": {1, 2},
+		"name:amstel":    {2, 3, 4},
+		"name:ams":       {5},
+		"name:antwerp":   {6},
+		"name:berlin":    {7, 8},
+		"name:amélie":    {9},
+		"country:nl":     {1, 2, 3},
+	})
+}
+
+func TestTermDictionary(t *testing.T) {
+	d := newTestDictionary()
+	terms := func(q *OrQuery, n int) []string {
+		out := []string{}
+		for _, s := range q.queries {
+			out = append(out, s.(*TermQuery).data.term)
+		}
+		return out
+	}
+	eqS := func(a, b []string) {
+		t.Helper()
+		if len(a) != len(b) {
+			t.Fatalf("%v != %v", a, b)
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("%v != %v", a, b)
+			}
+		}
+	}
+
+	if d.Len() != 7 || d.Postings("name:berlin")[1] != 8 || d.Postings("name:b") != nil {
+		t.Fatal("postings")
+	}
+	eq(t, []int32{7, 8}, query(d.Term("name:berlin")))
+	eq(t, []int32{}, query(d.Term("name:paris")))
+
+	eqS([]string{"name:ams", "name:amstel", "name:amsterdam"}, terms(d.Prefix("name:ams")))
+	eqS([]string{}, terms(d.Prefix("name:x")))
+	eqS([]string{"name:ams", "name:amstel", "name:amsterdam", "name:amélie", "name:antwerp", "name:berlin"}, terms(d.Prefix("name:")))
+	q, n := d.Prefix("name:ams")
+	eq(t, []int32{1, 2, 3, 4, 5}, query(q))
+	if n != 3 {
+		t.Fatalf("matching %d", n)
+	}
+
+	eqS([]string{"name:amstel", "name:amsterdam"}, terms(d.Wildcard("name:ams?e*")))
+	eqS([]string{"name:amsterdam"}, terms(d.Wildcard("name:a*dam")))
+	eqS([]string{"name:amélie"}, terms(d.Wildcard("name:am?lie")))
+	eqS([]string{"name:ams"}, terms(d.Wildcard("name:ams")))
+	eqS([]string{"country:nl", "name:antwerp", "name:berlin"}, terms(d.Wildcard("*:*n*")))
+
+	r, n, err := d.Regexp("name:am(s|é).*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eqS([]string{"name:ams", "name:amstel", "name:amsterdam", "name:amélie"}, terms(r, n))
+	r, n, _ = d.Regexp("name:ams")
+	eqS([]string{"name:ams"}, terms(r, n))
+	r, n, _ = d.Regexp("[a-z]+:.*n")
+	eqS([]string{"name:berlin"}, terms(r, n))
+	if _, _, err := d.Regexp("name:(ams"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// keep the terms with most documents
+	old := MAX_EXPANSIONS
+	defer func() {
+		MAX_EXPANSIONS = old
+	}()
+	MAX_EXPANSIONS = 2
+	eqS([]string{"name:amstel", "name:amsterdam"}, terms(d.Prefix("name:am")))
+	if _, n := d.Prefix("name:am"); n != 4 {
+		t.Fatalf("truncated %d", n)
+	}
+	if _, n := d.Wildcard("name:a*"); n != 5 {
+		t.Fatalf("truncated %d", n)
+	}
+	MAX_EXPANSIONS = 0
+	eqS([]string{}, terms(d.Prefix("name:am")))
+	if q, _ := d.Prefix("name:am"); !isMatchNone(Rewrite(q)) {
+		t.Fatal("rewrite")
+	}
+}
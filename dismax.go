@@ -1,6 +1,9 @@
 package query
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type DisMaxQuery struct {
 	queries    []Query
@@ -68,6 +71,46 @@ func (q *DisMaxQuery) Score() float32 {
 	return (max + ((sum - max) * q.tieBreaker)) * q.boost
 }
 
+func (q *DisMaxQuery) UpperBound() float32 {
+	sum := float32(0)
+	max := float32(0)
+	for _, s := range q.queries {
+		ub := s.UpperBound()
+		if ub > max {
+			max = ub
+		}
+		sum += ub
+	}
+	return boostUpperBound(disMaxUpperBound(max, sum, q.tieBreaker), q.boost)
+}
+
+func (q *DisMaxQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, "dis_max")
+	}
+
+	details := []*Explanation{}
+	sum := float32(0)
+	max := float32(0)
+	for _, s := range q.queries {
+		if s.GetDocId() == q.docId {
+			subQueryScore := s.Score()
+			if subQueryScore > max {
+				max = subQueryScore
+			}
+			sum += subQueryScore
+			details = append(details, s.Explain())
+		} else {
+			details = append(details, explainNoMatch(s.String()))
+		}
+	}
+	details = append(details,
+		explainMatch(max, "max"),
+		explainMatch((sum-max)*q.tieBreaker, fmt.Sprintf("tie breaker %.2f * (sum - max)", q.tieBreaker)),
+	)
+	return explainBoost(explainMatch(q.Score(), "dis_max, max plus tie breaker * others of:", details...), q.boost)
+}
+
 func (q *DisMaxQuery) Advance(target int32) int32 {
 	newDoc := NO_MORE
 	n := len(q.queries)
@@ -120,3 +163,20 @@ func (q *DisMaxQuery) SetBoost(b float32) Query {
 func (q *DisMaxQuery) PayloadDecode(p Payload) {
 	panic("unsupported")
 }
+
+func (q *DisMaxQuery) Err() error {
+	return firstErr(q.queries)
+}
+
+func (q *DisMaxQuery) Close() error {
+	return closeAll(q.queries)
+}
+
+func (q *DisMaxQuery) Reset() {
+	resetAll(q.queries)
+	q.docId = NOT_READY
+}
+
+func (q *DisMaxQuery) Clone() Query {
+	return DisMax(q.tieBreaker, cloneAll(q.queries)...).SetBoost(q.boost)
+}
@@ -0,0 +1,126 @@
+package query
+
+import "fmt"
+
+type DocRangeQuery struct {
+	min   int32
+	max   int32
+	docId int32
+	boost float32
+	// created by MatchAll()
+	all bool
+}
+
+// Creates query that matches every document from min to max
+// (inclusive) without any postings, the score is the boost (1 by
+// default). Useful when the document ids are ordered by time, e.g.
+// documents ingested after document 1000:
+//
+//	And(Term(n, "name:amsterdam", ...), DocRange(1001, n-1))
+//
+// The cost is the number of documents left in the range, so a wide
+// range is not picked as the leading query of AND
+func DocRange(min, max int32) *DocRangeQuery {
+	if min < 0 {
+		min = 0
+	}
+	return &DocRangeQuery{
+		min:   min,
+		max:   max,
+		docId: NOT_READY,
+		boost: 1,
+	}
+}
+
+func (q *DocRangeQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *DocRangeQuery) Cost() int {
+	if q.docId == NO_MORE || q.min > q.max {
+		return 0
+	}
+	if q.docId == NOT_READY || q.docId < q.min {
+		return int(q.max-q.min) + 1
+	}
+	return int(q.max-q.docId) + 1
+}
+
+func (q *DocRangeQuery) Score() float32 {
+	return q.boost
+}
+
+func (q *DocRangeQuery) UpperBound() float32 {
+	return q.boost
+}
+
+func (q *DocRangeQuery) Advance(target int32) int32 {
+	if target < q.min {
+		target = q.min
+	}
+	if target <= q.docId {
+		return q.docId
+	}
+	if target > q.max || target == NO_MORE {
+		q.docId = NO_MORE
+	} else {
+		q.docId = target
+	}
+	return q.docId
+}
+
+func (q *DocRangeQuery) Next() int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.Advance(q.docId + 1)
+}
+
+func (q *DocRangeQuery) Explain() *Explanation {
+	name := "doc range " + q.String()
+	if q.all {
+		name = "match all"
+	}
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, name)
+	}
+	return explainMatch(q.boost, name+", boost")
+}
+
+func (q *DocRangeQuery) String() string {
+	if q.all {
+		return fmt.Sprintf("*:%d", q.max+1)
+	}
+	return fmt.Sprintf("[%d TO %d]", q.min, q.max)
+}
+
+func (q *DocRangeQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+// nothing to decode, there is no payload
+func (q *DocRangeQuery) PayloadDecode(p Payload) {}
+
+func (q *DocRangeQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (q *DocRangeQuery) Err() error {
+	return nil
+}
+
+// nothing to release
+func (q *DocRangeQuery) Close() error {
+	return nil
+}
+
+func (q *DocRangeQuery) Reset() {
+	q.docId = NOT_READY
+}
+
+func (q *DocRangeQuery) Clone() Query {
+	c := *q
+	c.Reset()
+	return &c
+}
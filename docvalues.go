@@ -0,0 +1,149 @@
+package query
+
+import (
+	"math"
+	"sort"
+)
+
+// If a numeric range matches more than 1/NUMERIC_RANGE_SCAN_RATIO of
+// the documents it scans the column instead of collecting and sorting
+// the matching document ids from the points
+var NUMERIC_RANGE_SCAN_RATIO = 8
+
+type point struct {
+	value int64
+	docId int32
+}
+
+// Column oriented store of one numeric value per document, e.g. price
+// or timestamp. Use either SetInt64 or SetFloat64 for the same column,
+// floats are stored as sortable int64 bits.
+//
+// Next to the column it keeps the values sorted (the points), so range
+// queries find the matching documents with binary search instead of
+// scanning all of them
+//
+// The values are set by a single writer, which calls Freeze() to build
+// the points before sharing it, after that reading the values and
+// range queries are safe from many goroutines without locks. Without
+// Freeze() the first range query builds the points, so it can be used
+// only from one goroutine. Setting a value drops the points
+type DocValues struct {
+	name   string
+	values []int64
+	exists []bool
+	points []point
+	built  bool
+}
+
+func NewDocValues(name string) *DocValues {
+	return &DocValues{name: name}
+}
+
+func (dv *DocValues) set(docId int32, v int64) {
+	for int(docId) >= len(dv.values) {
+		dv.values = append(dv.values, 0)
+		dv.exists = append(dv.exists, false)
+	}
+	dv.values[docId] = v
+	dv.exists[docId] = true
+	dv.points = nil
+	dv.built = false
+}
+
+func (dv *DocValues) SetInt64(docId int32, v int64) *DocValues {
+	dv.set(docId, v)
+	return dv
+}
+
+func (dv *DocValues) SetFloat64(docId int32, v float64) *DocValues {
+	dv.set(docId, float64ToSortable(v))
+	return dv
+}
+
+// Returns the value of the document and false if it has no value
+func (dv *DocValues) Int64(docId int32) (int64, bool) {
+	if docId < 0 || int(docId) >= len(dv.values) || !dv.exists[docId] {
+		return 0, false
+	}
+	return dv.values[docId], true
+}
+
+// Returns the value of the document and false if it has no value
+func (dv *DocValues) Float64(docId int32) (float64, bool) {
+	v, ok := dv.Int64(docId)
+	if !ok {
+		return 0, false
+	}
+	return sortableToFloat64(v), true
+}
+
+// Returns the biggest document id with value + 1
+func (dv *DocValues) MaxDoc() int32 {
+	return int32(len(dv.values))
+}
+
+func (dv *DocValues) Name() string {
+	return dv.name
+}
+
+func (dv *DocValues) buildPoints() {
+	dv.built = true
+	dv.points = []point{}
+	for docId, v := range dv.values {
+		if dv.exists[docId] {
+			dv.points = append(dv.points, point{value: v, docId: int32(docId)})
+		}
+	}
+	sort.Slice(dv.points, func(i, j int) bool {
+		if dv.points[i].value == dv.points[j].value {
+			return dv.points[i].docId < dv.points[j].docId
+		}
+		return dv.points[i].value < dv.points[j].value
+	})
+}
+
+// Builds the points when all the values are set, call it before the
+// range queries run from many goroutines
+func (dv *DocValues) Freeze() *DocValues {
+	if !dv.built {
+		dv.buildPoints()
+	}
+	return dv
+}
+
+// returns the points with lo <= value <= hi
+func (dv *DocValues) pointsInRange(lo, hi int64) []point {
+	dv.Freeze()
+	points := dv.points
+
+	from := sort.Search(len(points), func(i int) bool {
+		return points[i].value >= lo
+	})
+	to := sort.Search(len(points), func(i int) bool {
+		return points[i].value > hi
+	})
+	if to < from {
+		to = from
+	}
+	return points[from:to]
+}
+
+// flips the bits of the negative numbers so the int64 order is the
+// same as the float64 order, -0 is stored as 0 so they are equal like
+// in float64 comparison, all NaNs are stored as one NaN that is sorted
+// after +Inf, so it is not in any range of numbers
+func float64ToSortable(f float64) int64 {
+	if f == 0 {
+		f = 0
+	}
+	if math.IsNaN(f) {
+		f = math.NaN()
+	}
+	bits := int64(math.Float64bits(f))
+	return bits ^ ((bits >> 63) & math.MaxInt64)
+}
+
+func sortableToFloat64(bits int64) float64 {
+	return math.Float64frombits(uint64(bits ^ ((bits >> 63) & math.MaxInt64)))
+}
@@ -0,0 +1,137 @@
+package query
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDocValues(t *testing.T) {
+	dv := NewDocValues("price").SetInt64(1, 10).SetInt64(3, -5)
+	if v, ok := dv.Int64(3); !ok || v != -5 {
+		t.Fatal("int64")
+	}
+	if _, ok := dv.Int64(2); ok {
+		t.Fatal("missing")
+	}
+	if _, ok := dv.Int64(100); ok {
+		t.Fatal("out of range")
+	}
+	if dv.MaxDoc() != 4 {
+		t.Fatal("max doc")
+	}
+
+	floats := []float64{math.Inf(-1), -100.5, -1, -0.001, 0, 0.001, 1, 3.14, 1e10, math.Inf(1)}
+	for i := 1; i < len(floats); i++ {
+		if float64ToSortable(floats[i-1]) >= float64ToSortable(floats[i]) {
+			t.Fatalf("%f >= %f", floats[i-1], floats[i])
+		}
+		if sortableToFloat64(float64ToSortable(floats[i])) != floats[i] {
+			t.Fatalf("%f", floats[i])
+		}
+	}
+}
+
+func TestNumericRange(t *testing.T) {
+	old := NUMERIC_RANGE_SCAN_RATIO
+	defer func() {
+		NUMERIC_RANGE_SCAN_RATIO = old
+	}()
+
+	rand.Seed(0)
+	dv := NewDocValues("ts")
+	for i := 0; i < 10000; i++ {
+		if rand.Intn(10) > 0 {
+			dv.SetInt64(int32(i), int64(rand.Intn(1000)-500))
+		}
+	}
+
+	expected := func(lo, hi int64) []int32 {
+		out := []int32{}
+		for i := int32(0); i < dv.MaxDoc(); i++ {
+			if v, ok := dv.Int64(i); ok && v >= lo && v <= hi {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+
+	for _, ratio := range []int{0, 8, 1000000} {
+		NUMERIC_RANGE_SCAN_RATIO = ratio
+		for _, r := range [][]int64{{-500, 500}, {0, 0}, {10, 20}, {-1000, -499}, {499, 1000}, {20, 10}, {math.MinInt64, math.MaxInt64}} {
+			e := expected(r[0], r[1])
+			q := NumericRange(dv, r[0], r[1])
+			if q.Cost() != len(e) {
+				t.Fatalf("cost %d != %d", q.Cost(), len(e))
+			}
+			eq(t, e, query(q))
+		}
+
+		q := NumericRange(dv, -100, 100)
+		e := expected(-100, 100)
+		if q.Advance(5000) != e[sort32(e, 5000)] || q.Next() != e[sort32(e, 5000)+1] {
+			t.Fatal("advance")
+		}
+	}
+
+	eq(t, []int32{4, 9}, query(And(
+		NumericRange(NewDocValues("a").SetInt64(4, 7).SetInt64(9, 8).SetInt64(11, 9), 7, 8),
+		Term(100, "x", []int32{1, 4, 9, 11}),
+	)))
+
+	f := NewDocValues("price").SetFloat64(0, -1.5).SetFloat64(1, 2.25).SetFloat64(2, 0).SetFloat64(4, 100)
+	eq(t, []int32{0, 2}, query(FloatRange(f, -2, 0)))
+	eq(t, []int32{1, 2}, query(FloatRange(f, -0.5, 10)))
+	eq(t, []int32{0, 1, 2, 4}, query(FloatRange(f, math.Inf(-1), math.Inf(1))))
+	if s := FloatRange(f, -0.5, 10).String(); s != "price:[-0.5 TO 10]" {
+		t.Fatal(s)
+	}
+	if v, ok := f.Float64(1); !ok || v != 2.25 {
+		t.Fatal("float64")
+	}
+	if !isMatchNone(Rewrite(FloatRange(f, 3, 4))) {
+		t.Fatal("rewrite")
+	}
+
+	// -0 and 0 are the same value
+	negativeZero := math.Copysign(0, -1)
+	z := NewDocValues("z").SetFloat64(0, negativeZero).SetFloat64(1, 0).SetFloat64(2, -1)
+	eq(t, []int32{0, 1}, query(FloatRange(z, 0, 1)))
+	eq(t, []int32{0, 1}, query(FloatRange(z, negativeZero, negativeZero)))
+	eq(t, []int32{2}, query(FloatRange(z, -1, math.Nextafter(0, -1))))
+
+	// NaN is not in any range
+	nan := NewDocValues("nan").SetFloat64(0, math.NaN()).SetFloat64(1, 1).SetFloat64(2, math.Inf(1))
+	eq(t, []int32{1, 2}, query(FloatRange(nan, math.Inf(-1), math.Inf(1))))
+	eq(t, []int32{}, query(FloatRange(nan, math.NaN(), math.Inf(1))))
+	eq(t, []int32{}, query(FloatRange(nan, math.NaN(), math.NaN())))
+	if v, ok := nan.Float64(0); !ok || !math.IsNaN(v) {
+		t.Fatal("nan")
+	}
+
+	// advancing to an earlier document stays on the current one
+	for _, ratio := range []int{0, 8} {
+		NUMERIC_RANGE_SCAN_RATIO = ratio
+		q := NumericRange(NewDocValues("b").SetInt64(0, 1).SetInt64(1, 5).SetInt64(2, 1).SetInt64(3, 1), 1, 1)
+		if q.Advance(3) != 3 || q.Advance(1) != 3 || q.Next() != NO_MORE {
+			t.Fatalf("ratio %d: advance backwards", ratio)
+		}
+	}
+
+	// setting values after the points are built builds them again
+	frozen := NewDocValues("f").SetInt64(0, 1).SetInt64(1, 2).Freeze()
+	eq(t, []int32{0, 1}, query(NumericRange(frozen, 1, 2)))
+	frozen.SetInt64(2, 2).SetInt64(0, 5)
+	eq(t, []int32{1, 2}, query(NumericRange(frozen, 1, 2)))
+	eq(t, []int32{0}, query(NumericRange(frozen.Freeze(), 5, 5)))
+}
+
+// index of the first element >= target
+func sort32(a []int32, target int32) int {
+	for i, v := range a {
+		if v >= target {
+			return i
+		}
+	}
+	return len(a)
+}
@@ -0,0 +1,109 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explains how the score of the current document was computed, the
+// tree mirrors the query tree, e.g.
+//
+//	q := And(Term(10, "a", []int32{1, 2}), Term(10, "b", []int32{2}))
+//	for q.Next() != NO_MORE {
+//		fmt.Println(q.Explain().String())
+//	}
+//
+// will print:
+//
+//	4.190 = and, sum of:
+//	  2.398 = term b, tfidf
+//	    2.398 = idf, N: 10, d: 1
+//	    1.000 = tf
+//	    1.000 = boost
+//	  1.792 = term a, tfidf
+//	    1.792 = idf, N: 10, d: 2
+//	    1.000 = tf
+//	    1.000 = boost
+//
+// Explanation can be marshaled to json with encoding/json as well.
+type Explanation struct {
+	Match       bool           `json:"match"`
+	Value       float32        `json:"value"`
+	Description string         `json:"description"`
+	Details     []*Explanation `json:"details,omitempty"`
+}
+
+func explainMatch(value float32, description string, details ...*Explanation) *Explanation {
+	return &Explanation{
+		Match:       true,
+		Value:       value,
+		Description: description,
+		Details:     details,
+	}
+}
+
+func explainNoMatch(description string, details ...*Explanation) *Explanation {
+	return &Explanation{
+		Match:       false,
+		Description: description,
+		Details:     details,
+	}
+}
+
+func explainNotReady(docId int32, description string) *Explanation {
+	if docId == NOT_READY {
+		return explainNoMatch(description + ", not started")
+	}
+	return explainNoMatch(description + ", exhausted")
+}
+
+func explainTerm(value float32, description string, s Similarity, idf float32, N, d int, tf float32, boost float32) *Explanation {
+	return explainMatch(
+		value,
+		fmt.Sprintf("term %s, %s", description, similarityName(s)),
+		explainMatch(idf, fmt.Sprintf("idf, N: %d, d: %d", N, d)),
+		explainMatch(tf, "tf"),
+		explainMatch(boost, "boost"),
+	)
+}
+
+func explainBoost(e *Explanation, boost float32) *Explanation {
+	if boost != 1 {
+		e.Details = append(e.Details, explainMatch(boost, "boost"))
+	}
+	return e
+}
+
+func similarityName(s Similarity) string {
+	switch s.(type) {
+	case TFIDF:
+		return "tfidf"
+	case ConstantSimilarity:
+		return "constant"
+	case *BM25:
+		return "bm25"
+	case *Classic:
+		return "classic"
+	default:
+		return fmt.Sprintf("%T", s)
+	}
+}
+
+func (e *Explanation) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	if e.Match {
+		sb.WriteString(fmt.Sprintf("%.3f = %s\n", e.Value, e.Description))
+	} else {
+		sb.WriteString(fmt.Sprintf("no match = %s\n", e.Description))
+	}
+	for _, d := range e.Details {
+		d.write(sb, depth+1)
+	}
+}
+
+// Renders the explanation as indented text, one line per node
+func (e *Explanation) String() string {
+	sb := &strings.Builder{}
+	e.write(sb, 0)
+	return sb.String()
+}
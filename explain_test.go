@@ -0,0 +1,93 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	q := DisMax(0.5,
+		AndNot(
+			Term(10, "z", []int32{3}),
+			Term(10, "a", []int32{1, 2, 3}),
+			TermTF(10, 4, "b", termsWithFrequencies(2, []int32{2, 3})),
+		),
+		Or(
+			Term(10, "c", []int32{2}),
+			Constant(5, CreateFileTerm(10, "d", []int32{1})),
+		).SetBoost(2),
+	)
+
+	if q.Explain().Match {
+		t.Fatal("not started")
+	}
+
+	for q.Next() != NO_MORE {
+		e := q.Explain()
+		if !e.Match || e.Value != q.Score() {
+			t.Fatalf("explain %d: %v != %v", q.GetDocId(), e.Value, q.Score())
+		}
+
+		var walk func(e *Explanation)
+		walk = func(e *Explanation) {
+			if !e.Match && e.Value != 0 {
+				t.Fatalf("no match with value %v", e)
+			}
+			for _, d := range e.Details {
+				walk(d)
+			}
+		}
+		walk(e)
+
+		s := e.String()
+		if !strings.Contains(s, "dis_max") || !strings.Contains(s, "tie breaker 0.50") {
+			t.Fatal(s)
+		}
+
+		switch q.GetDocId() {
+		case 1:
+			if !strings.Contains(s, "no match = {b/") || !strings.Contains(s, "5.000 = constant") {
+				t.Fatal(s)
+			}
+		case 2:
+			if !strings.Contains(s, "3.000 = tf") || !strings.Contains(s, "must not, excluded when matching z/") {
+				t.Fatal(s)
+			}
+			if !strings.Contains(s, "2.000 = boost") || !strings.Contains(s, "idf, N: 10, d: 3") {
+				t.Fatal(s)
+			}
+		}
+	}
+
+	if q.Explain().Match {
+		t.Fatal("exhausted")
+	}
+
+	idx := newPositionalIndex("new york", "new big york")
+	p := Or(Phrase(idx.terms("new york")...), Near(1, true, idx.terms("new york")...))
+	p.Next()
+	s := p.Explain().String()
+	if !strings.Contains(s, "1.000 = phrase freq") || !strings.Contains(s, "sloppy freq, slop: 1, ordered: true") {
+		t.Fatal(s)
+	}
+	p.Next()
+	s = p.Explain().String()
+	if !strings.Contains(s, "0.500 = sloppy freq") || !strings.Contains(s, "no match = {\"new/") {
+		t.Fatal(s)
+	}
+
+	bm := Term(10, "x", []int32{1}).SetSimilarity(NewBM25(1.2, 0.75, nil, 0))
+	bm.Next()
+	b, err := json.Marshal(bm.Explain())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Explanation{}
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Match || decoded.Value != bm.Score() || len(decoded.Details) != 3 || decoded.Description != "term x, bm25" {
+		t.Fatal(string(b))
+	}
+}
@@ -3,57 +3,78 @@ package query
 import (
 	"encoding/binary"
 	"os"
+	"path/filepath"
 )
 
 var ByteOrder = binary.LittleEndian
 
 type FileTermData struct {
-	cursor   int32
-	postings *os.File
-	n        int32
-	docId    int32
-	closed   bool
-	boost    float32
-	idf      float32
+	cursor     int32
+	postings   *os.File
+	n          int32
+	docId      int32
+	closed     bool
+	boost      float32
+	idf        float32
+	total      int
+	similarity Similarity
+	err        error
 }
 
 // Create new lazy term from stored ByteOrder (by default little
-// endian) encoded array of integers
+// endian) encoded array of integers, panics if the file can not be
+// opened, use OpenFileTerm() to get the error instead
 //
 // The file will be closed automatically when the query is exhausted (reaches the end)
 //
-// WARNING: you must exhaust the query, otherwise you will leak file descriptors.
+// WARNING: you must exhaust or Close() the query, otherwise you will leak file descriptors.
 func FileTerm(totalDocumentsInIndex int, fn string) *FileTermData {
+	t, err := OpenFileTerm(totalDocumentsInIndex, fn)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Same as FileTerm() but returns the error if the file can not be
+// opened, missing file is not an error, the query just matches
+// nothing. Read errors while iterating stop the iteration and are
+// returned by Err()
+func OpenFileTerm(totalDocumentsInIndex int, fn string) (*FileTermData, error) {
 	file, err := os.OpenFile(fn, os.O_RDONLY, 0600)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &FileTermData{
-				cursor:   0,
-				postings: nil,
-				n:        0,
-				docId:    NO_MORE,
-				boost:    1,
-				idf:      0,
-				closed:   true,
-			}
+				cursor:     0,
+				postings:   nil,
+				n:          0,
+				docId:      NO_MORE,
+				boost:      1,
+				idf:        0,
+				closed:     true,
+				similarity: TFIDF{},
+			}, nil
 		}
-		panic(err)
+		return nil, err
 	}
 
 	s, err := file.Stat()
 	if err != nil {
-		panic(err)
+		file.Close()
+		return nil, err
 	}
 
 	n := int32(s.Size() / 4)
 	return &FileTermData{
-		cursor:   0,
-		postings: file,
-		n:        n,
-		docId:    NOT_READY,
-		boost:    1,
-		idf:      computeIDF(totalDocumentsInIndex, int(n)),
-	}
+		cursor:     0,
+		postings:   file,
+		n:          n,
+		docId:      NOT_READY,
+		boost:      1,
+		idf:        computeIDF(totalDocumentsInIndex, int(n)),
+		total:      totalDocumentsInIndex,
+		similarity: TFIDF{},
+	}, nil
 }
 
 func (t *FileTermData) GetDocId() int32 {
@@ -70,42 +91,126 @@ func (t *FileTermData) Cost() int {
 }
 
 func (t *FileTermData) String() string {
-	s, err := t.postings.Stat()
-	if err != nil {
-		panic(err)
+	return t.name()
+}
+
+// works even after the file is closed
+func (t *FileTermData) name() string {
+	if t.postings == nil {
+		return "<missing>"
 	}
-	return s.Name()
+	return filepath.Base(t.postings.Name())
 }
 
 func (t *FileTermData) Score() float32 {
-	return t.idf * t.boost
+	return t.similarity.Score(t.idf, 1, t.docId) * t.boost
+}
+
+// Score with the given similarity instead of TFIDF, the term
+// frequency is always 1
+func (t *FileTermData) SetSimilarity(s Similarity) *FileTermData {
+	t.similarity = s
+	if t.n > 0 {
+		t.idf = s.IDF(t.total, int(t.n))
+	}
+	return t
+}
+
+// Same as SetSimilarity(s)
+func (t *FileTermData) SetBM25(s *BM25) *FileTermData {
+	return t.SetSimilarity(s)
+}
+
+func (t *FileTermData) UpperBound() float32 {
+	if t.n == 0 {
+		return 0
+	}
+	return boostUpperBound(t.similarity.MaxScore(t.idf, 1), t.boost)
 }
 
-func (t *FileTermData) getAt(idx int32) uint32 {
+func (t *FileTermData) Explain() *Explanation {
+	name := t.name()
+	if t.docId == NOT_READY || t.docId == NO_MORE {
+		return explainNotReady(t.docId, "file term "+name)
+	}
+	return explainTerm(t.Score(), name, t.similarity, t.idf, t.total, int(t.n), 1, t.boost)
+}
+
+// on read error the query is exhausted and the error is kept for Err()
+func (t *FileTermData) getAt(idx int32) (int32, bool) {
 	b := []byte{0, 0, 0, 0}
-	_, err := t.postings.ReadAt(b, int64(idx*4))
+	_, err := t.postings.ReadAt(b, int64(idx)*4)
 	if err != nil {
-		panic(err)
+		if t.err == nil {
+			t.err = err
+		}
+		t.Close()
+		t.docId = NO_MORE
+		return NO_MORE, false
 	}
-	return ByteOrder.Uint32(b)
+	return int32(ByteOrder.Uint32(b)), true
+}
+
+func (t *FileTermData) Err() error {
+	return t.err
 }
-func (t *FileTermData) Close() {
+
+// If the file was already closed it is opened again
+func (t *FileTermData) Reset() {
+	t.cursor = 0
+	t.err = nil
+	if t.postings == nil {
+		// missing file
+		return
+	}
+	t.docId = NOT_READY
+	if t.closed {
+		f, err := os.OpenFile(t.postings.Name(), os.O_RDONLY, 0600)
+		if err != nil {
+			t.err = err
+			t.docId = NO_MORE
+			return
+		}
+		t.postings = f
+		t.closed = false
+	}
+}
+
+// The clone opens the file again, so it has its own file descriptor
+func (t *FileTermData) Clone() Query {
+	c := *t
+	c.closed = true
+	c.Reset()
+	return &c
+}
+
+// Closes the file, it is closed automatically when the query is
+// exhausted
+func (t *FileTermData) Close() error {
 	if !t.closed {
-		t.postings.Close()
 		t.closed = true
+		return t.postings.Close()
 	}
+	return nil
 }
+
 func (t *FileTermData) Advance(target int32) int32 {
-	if t.docId == NO_MORE || t.docId == target || target == NO_MORE {
-		t.docId = target
+	if t.docId == NO_MORE || target == NO_MORE {
+		t.docId = NO_MORE
 		t.Close()
+		return NO_MORE
+	}
+	if t.docId == target {
 		return t.docId
 	}
 	start := t.cursor
 	end := t.n
 	for start < end {
 		mid := start + ((end - start) / 2)
-		current := int32(t.getAt(mid))
+		current, ok := t.getAt(mid)
+		if !ok {
+			return NO_MORE
+		}
 		if current == target {
 			t.cursor = mid
 			t.docId = target
@@ -127,8 +232,8 @@ func (t *FileTermData) move(to int32) int32 {
 	if t.cursor >= t.n {
 		t.Close()
 		t.docId = NO_MORE
-	} else {
-		t.docId = int32(t.getAt(t.cursor))
+	} else if docId, ok := t.getAt(t.cursor); ok {
+		t.docId = docId
 	}
 	return t.docId
 }
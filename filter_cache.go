@@ -0,0 +1,110 @@
+package query
+
+import (
+	"container/list"
+	"sync"
+)
+
+type filterCacheEntry struct {
+	key    string
+	bitmap *Bitmap
+}
+
+// LRU cache of the documents matching filters (e.g. country:nl AND
+// category:bikes), the documents are kept in a Bitmap, so repeating
+// the filter is just iterating the bitmap and AND of cached filters
+// is bitmap intersection. It is safe to use from many goroutines
+type FilterCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List
+	hits     int
+	misses   int
+}
+
+// Creates cache that keeps the last capacity used filters
+func NewFilterCache(capacity int) *FilterCache {
+	return &FilterCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Returns query matching the documents of the filter stored under key,
+// on miss the query returned by create is iterated to the end and its
+// documents are cached. The score is constant 1, use SetBoost() to
+// change it, e.g.
+//
+//	Bool().
+//		Must(Term(n, "name:amsterdam", ...)).
+//		Filter(cache.Filter("country:nl", func() Query {
+//			return Term(n, "country:nl", ...)
+//		}))
+//
+// The key must identify the filter and the index it runs on. If the
+// query created by create fails, the documents found before the error
+// are returned, they are not cached and Err() of the returned query
+// reports the error
+func (c *FilterCache) Filter(key string, create func() Query) *RoaringTermQuery {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(e)
+		b := e.Value.(*filterCacheEntry).bitmap
+		c.mu.Unlock()
+		return c.query(key, b)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// iterate without holding the lock, two goroutines can compute
+	// the same filter, the last one wins
+	docs := []int32{}
+	q := create()
+	for q.Next() != NO_MORE {
+		docs = append(docs, q.GetDocId())
+	}
+	q.Close()
+	b := NewBitmap(docs)
+	if err := q.Err(); err != nil {
+		// partial result, do not cache it
+		r := c.query(key, b)
+		r.err = err
+		return r
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*filterCacheEntry).bitmap = b
+		c.lru.MoveToFront(e)
+	} else if c.capacity > 0 {
+		c.entries[key] = c.lru.PushFront(&filterCacheEntry{key: key, bitmap: b})
+		for c.lru.Len() > c.capacity {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*filterCacheEntry).key)
+		}
+	}
+	c.mu.Unlock()
+	return c.query(key, b)
+}
+
+func (c *FilterCache) query(key string, b *Bitmap) *RoaringTermQuery {
+	return RoaringTerm(b.Cardinality(), key, b).SetSimilarity(ConstantSimilarity{})
+}
+
+// Returns the number of cache hits and misses
+func (c *FilterCache) Stats() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Returns the number of cached filters
+func (c *FilterCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,157 @@
+package query
+
+import (
+	"sort"
+	"strings"
+)
+
+// Levenshtein automaton, the state is the last row of the edit
+// distance matrix between the query and the consumed characters
+type levenshtein struct {
+	query    []rune
+	maxEdits int
+}
+
+func (l *levenshtein) start() []int {
+	row := make([]int, len(l.query)+1)
+	for i := range row {
+		row[i] = i
+	}
+	return row
+}
+
+func (l *levenshtein) step(row []int, c rune) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if l.query[i-1] == c {
+			cost = 0
+		}
+		next[i] = min3(row[i]+1, next[i-1]+1, row[i-1]+cost)
+	}
+	return next
+}
+
+// false if no continuation can be within maxEdits
+func (l *levenshtein) canMatch(row []int) bool {
+	for _, v := range row {
+		if v <= l.maxEdits {
+			return true
+		}
+	}
+	return false
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func commonPrefix(a, b []rune) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Returns query that matches the terms within maxEdits (insertions,
+// deletions or substitutions) from term, the first prefixLength
+// characters have to match exactly, which makes it a lot faster, e.g.
+//
+//	dict.Fuzzy("name:amsterdma", 2, len("name:"))
+//
+// The matching terms are combined with DisMax, so a document that has
+// many variants is not scored higher, each variant is scored as its
+// idf boosted by 1 - distance/(maxEdits+1).
+//
+// The sorted terms are walked with the automaton, the states of the
+// common prefix with the previous term are reused and when a prefix
+// can not match anymore all the terms starting with it are skipped.
+// Keeps MAX_EXPANSIONS closest terms, the ones with most documents
+// first when the distance is the same, the number of all matching
+// terms is returned next to the query
+func (d *TermDictionary) Fuzzy(term string, maxEdits, prefixLength int) (*DisMaxQuery, int) {
+	runes := []rune(term)
+	if prefixLength > len(runes) {
+		prefixLength = len(runes)
+	}
+	if prefixLength < 0 {
+		prefixLength = 0
+	}
+	if maxEdits < 0 {
+		maxEdits = 0
+	}
+	prefix := string(runes[:prefixLength])
+	l := &levenshtein{query: runes[prefixLength:], maxEdits: maxEdits}
+
+	type variant struct {
+		idx      int
+		distance int
+	}
+	matching := []variant{}
+
+	from, to := d.prefixRange(prefix)
+	rows := [][]int{l.start()}
+	var previous []rune
+	for i := from; i < to; {
+		suffix := []rune(d.terms[i][len(prefix):])
+		depth := commonPrefix(previous, suffix)
+		if depth > len(rows)-1 {
+			depth = len(rows) - 1
+		}
+		rows = rows[:depth+1]
+		previous = suffix
+
+		dead := false
+		for len(rows) <= len(suffix) {
+			row := l.step(rows[len(rows)-1], suffix[len(rows)-1])
+			rows = append(rows, row)
+			if !l.canMatch(row) {
+				dead = true
+				break
+			}
+		}
+
+		if dead {
+			// skip all terms that start with the same dead prefix
+			dead := prefix + string(suffix[:len(rows)-1])
+			i += sort.Search(to-i, func(j int) bool {
+				return !strings.HasPrefix(d.terms[i+j], dead)
+			})
+			continue
+		}
+
+		if distance := rows[len(rows)-1][len(l.query)]; distance <= maxEdits {
+			matching = append(matching, variant{idx: i, distance: distance})
+		}
+		i++
+	}
+
+	total := len(matching)
+	if len(matching) > MAX_EXPANSIONS {
+		sort.SliceStable(matching, func(i, j int) bool {
+			if matching[i].distance == matching[j].distance {
+				return d.data[matching[i].idx].Len() > d.data[matching[j].idx].Len()
+			}
+			return matching[i].distance < matching[j].distance
+		})
+		matching = matching[:MAX_EXPANSIONS]
+		sort.SliceStable(matching, func(i, j int) bool {
+			return matching[i].idx < matching[j].idx
+		})
+	}
+
+	queries := make([]Query, len(matching))
+	for i, m := range matching {
+		boost := 1 - float32(m.distance)/float32(maxEdits+1)
+		queries[i] = d.data[m.idx].Query().SetBoost(boost)
+	}
+	return DisMax(0, queries...), total
+}
@@ -0,0 +1,109 @@
+package query
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func levenshteinDistance(a, b []rune) int {
+	l := &levenshtein{query: a}
+	row := l.start()
+	for _, c := range b {
+		row = l.step(row, c)
+	}
+	return row[len(a)]
+}
+
+func TestFuzzy(t *testing.T) {
+	d := newTestDictionary()
+	terms := func(q *DisMaxQuery, n int) []string {
+		out := []string{}
+		for _, s := range q.queries {
+			out = append(out, s.(*TermQuery).data.term)
+		}
+		return out
+	}
+	eqS := func(a, b []string) {
+		t.Helper()
+		if len(a) != len(b) {
+			t.Fatalf("%v != %v", a, b)
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("%v != %v", a, b)
+			}
+		}
+	}
+
+	eqS([]string{"name:amsterdam"}, terms(d.Fuzzy("name:amsterdma", 2, 5)))
+	eqS([]string{}, terms(d.Fuzzy("name:amsterdma", 1, 5)))
+	eqS([]string{"name:amstel"}, terms(d.Fuzzy("name:amstl", 1, 0)))
+	eqS([]string{"name:ams", "name:amstel"}, terms(d.Fuzzy("name:amst", 2, 5)))
+	eqS([]string{"name:amélie"}, terms(d.Fuzzy("name:amelie", 1, 5)))
+	eqS([]string{"name:berlin"}, terms(d.Fuzzy("name:berlin", 0, 100)))
+	eqS([]string{}, terms(d.Fuzzy("nome:berlin", 1, 2)))
+	eqS([]string{"name:berlin"}, terms(d.Fuzzy("nome:berlin", 1, 0)))
+	q, n := d.Fuzzy("name:amst", 2, 5)
+	eq(t, []int32{2, 3, 4, 5}, query(q))
+	if n != 2 {
+		t.Fatalf("matching %d", n)
+	}
+
+	// closer variants score higher
+	f, _ := d.Fuzzy("name:amste", 2, 5)
+	if f.Advance(2) != 2 || f.Score() != computeIDF(10, 3)*(1-float32(1)/3) {
+		t.Fatal("one edit")
+	}
+	if f.Advance(5) != 5 || f.Score() != computeIDF(10, 1)*(1-float32(2)/3) {
+		t.Fatal("two edits")
+	}
+
+	// keep the closest
+	old := MAX_EXPANSIONS
+	defer func() {
+		MAX_EXPANSIONS = old
+	}()
+	MAX_EXPANSIONS = 1
+	eqS([]string{"name:ams"}, terms(d.Fuzzy("name:amst", 2, 5)))
+	if _, n := d.Fuzzy("name:amst", 2, 5); n != 2 {
+		t.Fatalf("truncated %d", n)
+	}
+	MAX_EXPANSIONS = old
+
+	// same as brute force
+	rand.Seed(0)
+	letters := []rune("abcé")
+	word := func() string {
+		w := make([]rune, rand.Intn(7))
+		for i := range w {
+			w[i] = letters[rand.Intn(len(letters))]
+		}
+		return string(w)
+	}
+	dict := map[string][]int32{}
+	for i := 0; i < 2000; i++ {
+		dict[word()] = []int32{int32(i)}
+	}
+	d = NewTermDictionary(2000, dict)
+	for i := 0; i < 200; i++ {
+		w := word()
+		maxEdits := rand.Intn(3)
+		prefixLength := rand.Intn(3)
+		expected := []string{}
+		for term := range dict {
+			a, b := []rune(w), []rune(term)
+			if prefixLength > len(a) {
+				prefixLength = len(a)
+			}
+			if len(b) < prefixLength || string(a[:prefixLength]) != string(b[:prefixLength]) {
+				continue
+			}
+			if levenshteinDistance(a[prefixLength:], b[prefixLength:]) <= maxEdits {
+				expected = append(expected, term)
+			}
+		}
+		sort.Strings(expected)
+		eqS(expected, terms(d.Fuzzy(w, maxEdits, prefixLength)))
+	}
+}
@@ -0,0 +1,88 @@
+package query
+
+// MatchAll() is DocRange() over all the documents
+type MatchAllQuery = DocRangeQuery
+
+// Creates query that matches every document from 0 to maxDoc
+// (exclusive) without any postings, the score is the boost (1 by
+// default), it can be used as the positive side of AndNot() e.g.
+//
+//	AndNot(Term(n, "name:amsterdam", ...), MatchAll(n))
+//
+// matches every document that does not have name:amsterdam
+func MatchAll(maxDoc int32) *MatchAllQuery {
+	q := DocRange(0, maxDoc-1)
+	q.all = true
+	return q
+}
+
+type MatchNoneQuery struct {
+	docId int32
+}
+
+// Creates query that matches no documents, useful as a replacement of
+// branches that can not match, see Rewrite()
+func MatchNone() *MatchNoneQuery {
+	return &MatchNoneQuery{docId: NOT_READY}
+}
+
+func (q *MatchNoneQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *MatchNoneQuery) Cost() int {
+	return 0
+}
+
+func (q *MatchNoneQuery) Score() float32 {
+	return 0
+}
+
+func (q *MatchNoneQuery) UpperBound() float32 {
+	return 0
+}
+
+func (q *MatchNoneQuery) Advance(target int32) int32 {
+	q.docId = NO_MORE
+	return NO_MORE
+}
+
+func (q *MatchNoneQuery) Next() int32 {
+	q.docId = NO_MORE
+	return NO_MORE
+}
+
+func (q *MatchNoneQuery) Explain() *Explanation {
+	return explainNoMatch("match none")
+}
+
+func (q *MatchNoneQuery) String() string {
+	return "{}"
+}
+
+func (q *MatchNoneQuery) SetBoost(b float32) Query {
+	return q
+}
+
+func (q *MatchNoneQuery) PayloadDecode(p Payload) {}
+
+func (q *MatchNoneQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (q *MatchNoneQuery) Err() error {
+	return nil
+}
+
+// nothing to release
+func (q *MatchNoneQuery) Close() error {
+	return nil
+}
+
+func (q *MatchNoneQuery) Reset() {
+	q.docId = NOT_READY
+}
+
+func (q *MatchNoneQuery) Clone() Query {
+	return MatchNone()
+}
@@ -0,0 +1,267 @@
+package query
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+type MaxScoreQuery struct {
+	queries []Query
+	// queries sorted by upper bound, lowest first
+	sorted []int
+	// prefix sums of the sorted upper bounds
+	prefix []float32
+	// sorted[:essential] are the non essential queries
+	essential int
+	docId     int32
+	score     float32
+	threshold float32
+	boost     float32
+}
+
+// Creates MaxScore query, it matches the same documents and has the
+// same score as Or(queries...), but when used with a TopK collector it
+// skips documents that can not make it into the top k.
+//
+// The queries are sorted by their UpperBound() and split into non
+// essential (the ones with lowest upper bounds, that even all together
+// can not beat the minimum competitive score) and essential. Only the
+// essential queries are used to find the next candidate document, the
+// non essential are advanced only if the candidate still has a chance.
+// As the minimum competitive score rises more queries become non
+// essential, this works better than WAND when there are many queries.
+//
+// Keep in mind that with pruning the total hits count is a lower bound
+func MaxScore(queries ...Query) *MaxScoreQuery {
+	return &MaxScoreQuery{
+		queries:   queries,
+		docId:     NOT_READY,
+		threshold: float32(math.Inf(-1)),
+		boost:     1,
+	}
+}
+
+// Documents that can not score more than min are skipped
+func (q *MaxScoreQuery) SetMinCompetitiveScore(min float32) {
+	if min <= q.threshold {
+		return
+	}
+	q.threshold = min
+	if q.sorted != nil {
+		q.partition()
+	}
+}
+
+func (q *MaxScoreQuery) AddSubQuery(sub Query) Query {
+	q.queries = append(q.queries, sub)
+	q.sorted = nil
+	return q
+}
+
+func (q *MaxScoreQuery) prepare() {
+	upperBounds := make([]float32, len(q.queries))
+	q.sorted = make([]int, len(q.queries))
+	for i, s := range q.queries {
+		upperBounds[i] = s.UpperBound()
+		q.sorted[i] = i
+	}
+	sort.SliceStable(q.sorted, func(i, j int) bool {
+		return upperBounds[q.sorted[i]] < upperBounds[q.sorted[j]]
+	})
+
+	q.prefix = make([]float32, len(q.sorted))
+	sum := float32(0)
+	for i, idx := range q.sorted {
+		sum += upperBounds[idx]
+		q.prefix[i] = sum
+	}
+	q.partition()
+}
+
+func (q *MaxScoreQuery) competitive(ub float32, n int) bool {
+	return padUpperBound(boostUpperBound(ub, q.boost), n) > q.threshold
+}
+
+// moves the queries that together can not beat the threshold to the
+// non essential list
+func (q *MaxScoreQuery) partition() {
+	q.essential = 0
+	for q.essential < len(q.prefix) && !q.competitive(q.prefix[q.essential], q.essential+1) {
+		q.essential++
+	}
+}
+
+func (q *MaxScoreQuery) nextCompetitive(target int32) int32 {
+	if q.sorted == nil {
+		q.prepare()
+	}
+
+	for target != NO_MORE {
+		candidate := NO_MORE
+		for _, idx := range q.sorted[q.essential:] {
+			s := q.queries[idx]
+			curDoc := s.GetDocId()
+			if curDoc < target {
+				curDoc = s.Advance(target)
+			}
+			if curDoc < candidate {
+				candidate = curDoc
+			}
+		}
+		if candidate == NO_MORE {
+			break
+		}
+
+		score := float32(0)
+		for _, idx := range q.sorted[q.essential:] {
+			s := q.queries[idx]
+			if s.GetDocId() == candidate {
+				score += s.Score()
+			}
+		}
+
+		// check the non essential queries, highest upper bound first,
+		// as long as the candidate can still beat the threshold
+		pruned := false
+		for i := q.essential - 1; i >= 0; i-- {
+			if !q.competitive(score+q.prefix[i], len(q.queries)) {
+				pruned = true
+				break
+			}
+			s := q.queries[q.sorted[i]]
+			curDoc := s.GetDocId()
+			if curDoc < candidate {
+				curDoc = s.Advance(candidate)
+			}
+			if curDoc == candidate {
+				score += s.Score()
+			}
+		}
+
+		if !pruned {
+			q.score = q.scoreCurrent(candidate)
+			if q.score > q.threshold {
+				q.docId = candidate
+				return candidate
+			}
+		}
+		target = candidate + 1
+	}
+
+	q.docId = NO_MORE
+	q.score = 0
+	return NO_MORE
+}
+
+// same order as OrQuery.Score() so the scores are exactly the same
+func (q *MaxScoreQuery) scoreCurrent(docId int32) float32 {
+	score := float32(0)
+	for _, s := range q.queries {
+		if s.GetDocId() == docId {
+			score += s.Score()
+		}
+	}
+	return score * q.boost
+}
+
+func (q *MaxScoreQuery) Cost() int {
+	//XXX: optimistic, assume sets greatly overlap, which of course is not always true
+	max := 0
+	for _, sub := range q.queries {
+		if max < sub.Cost() {
+			max = sub.Cost()
+		}
+	}
+
+	return max
+}
+
+func (q *MaxScoreQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *MaxScoreQuery) Score() float32 {
+	return q.score
+}
+
+func (q *MaxScoreQuery) UpperBound() float32 {
+	sum := float32(0)
+	for _, s := range q.queries {
+		sum += s.UpperBound()
+	}
+	return boostUpperBound(sum, q.boost)
+}
+
+func (q *MaxScoreQuery) Advance(target int32) int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.nextCompetitive(target)
+}
+
+func (q *MaxScoreQuery) Next() int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.nextCompetitive(q.docId + 1)
+}
+
+func (q *MaxScoreQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, "max_score")
+	}
+
+	details := []*Explanation{}
+	for _, s := range q.queries {
+		if s.GetDocId() == q.docId {
+			details = append(details, s.Explain())
+		} else {
+			details = append(details, explainNoMatch(s.String()))
+		}
+	}
+	if !math.IsInf(float64(q.threshold), -1) {
+		details = append(details,
+			explainMatch(q.threshold, "min competitive score"),
+			explainMatch(float32(len(q.queries)-q.essential), "essential queries"),
+		)
+	}
+	return explainBoost(explainMatch(q.score, "max_score, sum of:", details...), q.boost)
+}
+
+func (q *MaxScoreQuery) String() string {
+	out := []string{}
+	for _, v := range q.queries {
+		out = append(out, v.String())
+	}
+	return "{" + strings.Join(out, " MAXSCORE ") + "}"
+}
+
+func (q *MaxScoreQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+func (q *MaxScoreQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (q *MaxScoreQuery) Err() error {
+	return firstErr(q.queries)
+}
+
+func (q *MaxScoreQuery) Close() error {
+	return closeAll(q.queries)
+}
+
+func (q *MaxScoreQuery) Reset() {
+	resetAll(q.queries)
+	q.sorted = nil
+	q.docId = NOT_READY
+	q.score = 0
+	q.threshold = float32(math.Inf(-1))
+}
+
+func (q *MaxScoreQuery) Clone() Query {
+	return MaxScore(cloneAll(q.queries)...).SetBoost(q.boost)
+}
@@ -0,0 +1,259 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// mapped file shared by the clones, unmapped when the last one is closed
+type mapping struct {
+	mu   sync.Mutex
+	data []byte
+	refs int
+}
+
+// false if it is already unmapped
+func (m *mapping) acquire() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refs == 0 {
+		return false
+	}
+	m.refs++
+	return true
+}
+
+func (m *mapping) release() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refs--
+	if m.refs == 0 {
+		data := m.data
+		m.data = nil
+		return munmapFile(data)
+	}
+	return nil
+}
+
+type MmapFileTermData struct {
+	term *TermQuery
+	// nil if the file is missing or empty
+	mapping *mapping
+	fn      string
+	closed  bool
+	err     error
+}
+
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// Same as FileTerm() but the file is memory mapped (on linux, on other
+// systems it is read in memory), so the postings are read from the
+// mapped memory without syscalls and the same block skip index as
+// Term() is used, panics if the file can not be opened, use
+// OpenMmapFileTerm() to get the error instead
+//
+// The file is unmapped automatically when the query is exhausted
+// (reaches the end)
+//
+// WARNING: you must exhaust or Close() the query, otherwise you will leak the mapping.
+func MmapFileTerm(totalDocumentsInIndex int, fn string) *MmapFileTermData {
+	t, err := OpenMmapFileTerm(totalDocumentsInIndex, fn)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Same as MmapFileTerm() but returns the error if the file can not be
+// opened or mapped, missing file is not an error, the query just
+// matches nothing
+func OpenMmapFileTerm(totalDocumentsInIndex int, fn string) (*MmapFileTermData, error) {
+	name := filepath.Base(fn)
+	file, err := os.OpenFile(fn, os.O_RDONLY, 0600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			q := &MmapFileTermData{term: Term(totalDocumentsInIndex, name, []int32{}), fn: fn, closed: true}
+			q.term.docId = NO_MORE
+			return q, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	s, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(s.Size() / 4)
+	if n == 0 {
+		return &MmapFileTermData{term: Term(totalDocumentsInIndex, name, []int32{}), fn: fn}, nil
+	}
+
+	data, err := mmapFile(file, n*4)
+	if err != nil {
+		return nil, err
+	}
+	return &MmapFileTermData{
+		term:    Term(totalDocumentsInIndex, name, bytesToPostings(data, n)),
+		mapping: &mapping{data: data, refs: 1},
+		fn:      fn,
+	}, nil
+}
+
+// the mapped bytes are used directly when the host is little endian
+func bytesToPostings(data []byte, n int) []int32 {
+	if !nativeLittleEndian {
+		out := make([]int32, n)
+		for i := range out {
+			out[i] = int32(ByteOrder.Uint32(data[i*4:]))
+		}
+		return out
+	}
+
+	var out []int32
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&out))
+	h.Data = uintptr(unsafe.Pointer(&data[0]))
+	h.Len = n
+	h.Cap = n
+	return out
+}
+
+// Unmaps the file, it is unmapped automatically when the query is
+// exhausted, the mapping is shared with the clones, so it is unmapped
+// when all of them are closed
+func (t *MmapFileTermData) Close() error {
+	if !t.closed {
+		t.closed = true
+		if t.mapping != nil {
+			return t.mapping.release()
+		}
+	}
+	return nil
+}
+
+func (t *MmapFileTermData) Err() error {
+	return t.err
+}
+
+// maps the file again, keeps the boost and the similarity
+func (t *MmapFileTermData) reopen() *MmapFileTermData {
+	q, err := OpenMmapFileTerm(t.term.data.totalDocs, t.fn)
+	if err != nil {
+		q = &MmapFileTermData{term: Term(t.term.data.totalDocs, filepath.Base(t.fn), []int32{}), fn: t.fn, closed: true, err: err}
+		q.term.docId = NO_MORE
+	}
+	q.term.SetSimilarity(t.term.similarity).SetBoost(t.term.boost)
+	return q
+}
+
+// If the query was already closed the file is mapped again
+func (t *MmapFileTermData) Reset() {
+	if !t.closed {
+		t.term.Reset()
+		return
+	}
+	if t.mapping != nil && t.mapping.acquire() {
+		t.closed = false
+		t.term.Reset()
+		return
+	}
+	*t = *t.reopen()
+}
+
+// The mapping and the block index are shared, if the query was
+// already closed the file is mapped again
+func (t *MmapFileTermData) Clone() Query {
+	if !t.closed && t.mapping != nil && t.mapping.acquire() {
+		return &MmapFileTermData{
+			term:    t.term.Clone().(*TermQuery),
+			mapping: t.mapping,
+			fn:      t.fn,
+		}
+	}
+	if !t.closed {
+		// empty file
+		return &MmapFileTermData{term: t.term.Clone().(*TermQuery), fn: t.fn}
+	}
+	return t.reopen()
+}
+
+func (t *MmapFileTermData) GetDocId() int32 {
+	return t.term.docId
+}
+
+func (t *MmapFileTermData) SetBoost(b float32) Query {
+	t.term.SetBoost(b)
+	return t
+}
+
+func (t *MmapFileTermData) Cost() int {
+	if t.closed {
+		return 0
+	}
+	return t.term.Cost()
+}
+
+func (t *MmapFileTermData) String() string {
+	return t.term.String()
+}
+
+func (t *MmapFileTermData) Score() float32 {
+	return t.term.Score()
+}
+
+// Score with the given similarity instead of TFIDF, the term
+// frequency is always 1
+func (t *MmapFileTermData) SetSimilarity(s Similarity) *MmapFileTermData {
+	t.term.SetSimilarity(s)
+	return t
+}
+
+func (t *MmapFileTermData) UpperBound() float32 {
+	return t.term.UpperBound()
+}
+
+func (t *MmapFileTermData) BlockUpperBound(target int32) (int32, float32) {
+	return t.term.BlockUpperBound(target)
+}
+
+func (t *MmapFileTermData) Explain() *Explanation {
+	return t.term.Explain()
+}
+
+func (t *MmapFileTermData) done(docId int32) int32 {
+	if docId == NO_MORE {
+		t.Close()
+	}
+	return docId
+}
+
+func (t *MmapFileTermData) Advance(target int32) int32 {
+	if t.closed {
+		t.term.docId = NO_MORE
+		return NO_MORE
+	}
+	return t.done(t.term.Advance(target))
+}
+
+func (t *MmapFileTermData) Next() int32 {
+	if t.closed {
+		t.term.docId = NO_MORE
+		return NO_MORE
+	}
+	return t.done(t.term.Next())
+}
+
+func (t *MmapFileTermData) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (t *MmapFileTermData) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
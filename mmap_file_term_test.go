@@ -0,0 +1,75 @@
+package query
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+)
+
+func CreateMmapFileTerm(n int, postings []int32) *MmapFileTermData {
+	dir, err := ioutil.TempDir("", "tt")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := path.Join(dir, fmt.Sprintf("t_%d", rand.Int()))
+
+	err = AppendFileNameTerm(fn, postings)
+	if err != nil {
+		panic(err)
+	}
+
+	return MmapFileTerm(n, fn)
+}
+
+func TestMmapFileTerm(t *testing.T) {
+	old := TERM_CHUNK_SIZE
+	defer func() {
+		TERM_CHUNK_SIZE = old
+	}()
+	TERM_CHUNK_SIZE = 16
+
+	rand.Seed(0)
+	for _, n := range []int{0, 1, 100, 5000} {
+		postings := uniquePostingsList(n)
+		eq(t, postings, query(CreateMmapFileTerm(10, postings)))
+		eqF(t, queryScores(Term(1000000, "x", postings)), queryScores(CreateMmapFileTerm(1000000, postings)))
+
+		for k := 0; k < 10; k++ {
+			a, b := Term(10, "x", postings), CreateMmapFileTerm(10, postings)
+			for a.GetDocId() != NO_MORE {
+				var da, db int32
+				if rand.Intn(2) == 0 {
+					da, db = a.Next(), b.Next()
+				} else {
+					target := a.GetDocId() + int32(rand.Intn(100000))
+					da, db = a.Advance(target), b.Advance(target)
+				}
+				if da != db {
+					t.Fatalf("%d != %d", da, db)
+				}
+			}
+			if !b.closed || b.mapping != nil && b.mapping.data != nil {
+				t.Fatal("not closed")
+			}
+			// after unmap
+			if b.Next() != NO_MORE || b.Advance(0) != NO_MORE || b.Cost() != 0 {
+				t.Fatal("closed")
+			}
+		}
+	}
+
+	postings := uniquePostingsList(1000)
+	eq(t, postings[1:], query(And(CreateMmapFileTerm(1000000, postings), Term(1000000, "y", postings[1:]))))
+	hits, _ := TopK(WAND(CreateMmapFileTerm(1000000, postings), Term(1000000, "y", postings[:500])), 10)
+	expected, _ := TopK(Or(Term(1000000, "x", postings), Term(1000000, "y", postings[:500])), 10)
+	eqHits(t, expected, hits)
+
+	eq(t, []int32{}, query(MmapFileTerm(10, "/tmp/must_not_exist_some_random_file")))
+	if !isMatchNone(Rewrite(CreateMmapFileTerm(10, []int32{}))) {
+		t.Fatal("rewrite")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package query
+
+import (
+	"os"
+	"syscall"
+)
+
+// maps the whole file read only, the file can be closed after that
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package query
+
+import (
+	"io"
+	"os"
+)
+
+// no mmap, reads the whole file in memory
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(f, b)
+	return b, err
+}
+
+func munmapFile(b []byte) error {
+	return nil
+}
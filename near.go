@@ -0,0 +1,272 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NearQuery struct {
+	terms   []PositionalQuery
+	conj    *AndQuery
+	slop    int32
+	ordered bool
+	docId   int32
+	freq    float32
+	boost   float32
+}
+
+// Creates proximity query (a.k.a sloppy phrase or span near), matches
+// documents in which the terms appear within slop positions of each
+// other, if ordered is true they also have to appear in the given order.
+//
+// The slop is the number of positions the terms have to be moved to
+// become an exact phrase, e.g. for {new big york}:
+//
+//	Near(0, true, new, york)  // no match
+//	Near(1, true, new, york)  // match
+//	Near(1, true, york, new)  // no match
+//	Near(1, false, york, new) // match
+//
+// Every match contributes 1/(1+distance) to the sloppy frequency so
+// closer matches score higher, an exact phrase contributes 1. The
+// score is the sum of the terms scores multiplied by the sloppy
+// frequency, so Near(0, true, ...) scores the same as Phrase(...).
+func Near(slop int, ordered bool, terms ...PositionalQuery) *NearQuery {
+	queries := make([]Query, len(terms))
+	for i, t := range terms {
+		queries[i] = t
+	}
+
+	return &NearQuery{
+		terms:   terms,
+		conj:    And(queries...),
+		slop:    int32(slop),
+		ordered: ordered,
+		docId:   NOT_READY,
+		boost:   1,
+	}
+}
+
+// Walks the positions of each term looking for the closest position
+// after the previous term, for each position of the first term
+func (q *NearQuery) orderedFreq(positions [][]int32, cursors []int) float32 {
+	n := int32(len(positions))
+	freq := float32(0)
+	for _, start := range positions[0] {
+		prev := start
+		for i := 1; i < len(positions); i++ {
+			for cursors[i] < len(positions[i]) && positions[i][cursors[i]] <= prev {
+				cursors[i]++
+			}
+			if cursors[i] == len(positions[i]) {
+				return freq
+			}
+			prev = positions[i][cursors[i]]
+		}
+
+		distance := prev - start - (n - 1)
+		if distance <= q.slop {
+			freq += 1 / float32(1+distance)
+		}
+	}
+	return freq
+}
+
+// Moves the cursors of terms that are on the same position as an
+// earlier term (the same term repeated in the query), so each term of
+// the window is on its own position, false if a term runs out of them
+func distinctPositions(positions [][]int32, cursors []int) bool {
+	for moved := true; moved; {
+		moved = false
+		for i := 1; i < len(positions); i++ {
+			for j := 0; j < i; j++ {
+				if positions[i][cursors[i]] == positions[j][cursors[j]] {
+					cursors[i]++
+					if cursors[i] == len(positions[i]) {
+						return false
+					}
+					moved = true
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Slides a window over the positions of all terms, always moving the
+// term with the smallest position, each window that fits the slop is
+// a match
+func (q *NearQuery) unorderedFreq(positions [][]int32, cursors []int) float32 {
+	n := int32(len(positions))
+	freq := float32(0)
+	for {
+		if !distinctPositions(positions, cursors) {
+			return freq
+		}
+
+		minTerm := 0
+		min := NO_MORE
+		max := NOT_READY
+		for i, p := range positions {
+			current := p[cursors[i]]
+			if current < min {
+				min = current
+				minTerm = i
+			}
+			if current > max {
+				max = current
+			}
+		}
+
+		distance := max - min - (n - 1)
+		if distance <= q.slop {
+			freq += 1 / float32(1+distance)
+		}
+
+		cursors[minTerm]++
+		if cursors[minTerm] == len(positions[minTerm]) {
+			return freq
+		}
+	}
+}
+
+// Returns the sloppy frequency of the current document, expects all
+// terms to be on the same document
+func (q *NearQuery) sloppyFreq() float32 {
+	positions := make([][]int32, len(q.terms))
+	for i, t := range q.terms {
+		positions[i] = t.Positions()
+		if len(positions[i]) == 0 {
+			return 0
+		}
+	}
+	cursors := make([]int, len(positions))
+
+	if q.ordered {
+		return q.orderedFreq(positions, cursors)
+	}
+	return q.unorderedFreq(positions, cursors)
+}
+
+func (q *NearQuery) nextNear(target int32) int32 {
+	for target != NO_MORE {
+		q.freq = q.sloppyFreq()
+		if q.freq > 0 {
+			break
+		}
+		target = q.conj.Next()
+	}
+	q.docId = target
+	return target
+}
+
+func (q *NearQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *NearQuery) Cost() int {
+	return q.conj.Cost()
+}
+
+func (q *NearQuery) Score() float32 {
+	score := float32(0)
+	for _, t := range q.terms {
+		score += t.Score()
+	}
+	return score * q.freq * q.boost
+}
+
+// The sloppy frequency is not known upfront
+func (q *NearQuery) UpperBound() float32 {
+	if len(q.terms) == 0 {
+		return 0
+	}
+	return boostUpperBound(unknownUpperBound, q.boost)
+}
+
+func (q *NearQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE || len(q.terms) == 0 {
+		return explainNotReady(q.docId, "near")
+	}
+
+	details := []*Explanation{}
+	for _, t := range q.terms {
+		details = append(details, t.Explain())
+	}
+	details = append(details, explainMatch(q.freq, fmt.Sprintf("sloppy freq, slop: %d, ordered: %v", q.slop, q.ordered)))
+	return explainBoost(explainMatch(q.Score(), "near, sum of terms * sloppy freq:", details...), q.boost)
+}
+
+func (q *NearQuery) Advance(target int32) int32 {
+	if len(q.terms) == 0 {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+	return q.nextNear(q.conj.Advance(target))
+}
+
+func (q *NearQuery) Next() int32 {
+	if len(q.terms) == 0 {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+	return q.nextNear(q.conj.Next())
+}
+
+func (q *NearQuery) String() string {
+	out := []string{}
+	for _, v := range q.terms {
+		out = append(out, v.String())
+	}
+	order := "unordered"
+	if q.ordered {
+		order = "ordered"
+	}
+	return fmt.Sprintf("{\"%s\"~%d,%s}", strings.Join(out, " "), q.slop, order)
+}
+
+func (q *NearQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+func (q *NearQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (q *NearQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (q *NearQuery) Err() error {
+	for _, t := range q.terms {
+		if err := t.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *NearQuery) Close() error {
+	var first error
+	for _, t := range q.terms {
+		if err := t.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (q *NearQuery) Reset() {
+	q.conj.Reset()
+	q.docId = NOT_READY
+	q.freq = 0
+}
+
+func (q *NearQuery) Clone() Query {
+	terms := make([]PositionalQuery, len(q.terms))
+	for i, t := range q.terms {
+		terms[i] = t.Clone().(PositionalQuery)
+	}
+	return Near(int(q.slop), q.ordered, terms...).SetBoost(q.boost)
+}
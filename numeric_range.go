@@ -0,0 +1,184 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type NumericRangeQuery struct {
+	dv    *DocValues
+	lo    int64
+	hi    int64
+	float bool
+	count int
+	// matching document ids, nil when scanning the column
+	docs   []int32
+	cursor int
+	docId  int32
+	boost  float32
+}
+
+// Creates query that matches the documents with lo <= value <= hi in
+// the doc values column, the score is the boost (1 by default), e.g.
+//
+//	price := NewDocValues("price")
+//	price.SetInt64(0, 100).SetInt64(1, 250)
+//	And(Term(n, "name:amsterdam", ...), NumericRange(price, 100, 200))
+//
+// The cost is the exact number of matching documents
+func NumericRange(dv *DocValues, lo, hi int64) *NumericRangeQuery {
+	q := &NumericRangeQuery{
+		dv:    dv,
+		lo:    lo,
+		hi:    hi,
+		docId: NOT_READY,
+		boost: 1,
+	}
+	q.prepare()
+	return q
+}
+
+// Same as NumericRange but for columns of float64 values, NaN is not
+// in any range and the range with NaN bound matches nothing
+func FloatRange(dv *DocValues, lo, hi float64) *NumericRangeQuery {
+	q := &NumericRangeQuery{
+		dv:    dv,
+		lo:    float64ToSortable(lo),
+		hi:    float64ToSortable(hi),
+		float: true,
+		docId: NOT_READY,
+		boost: 1,
+	}
+	if math.IsNaN(lo) || math.IsNaN(hi) {
+		q.docs = []int32{}
+		return q
+	}
+	q.prepare()
+	return q
+}
+
+func (q *NumericRangeQuery) prepare() {
+	points := q.dv.pointsInRange(q.lo, q.hi)
+	q.count = len(points)
+	if q.count*NUMERIC_RANGE_SCAN_RATIO > int(q.dv.MaxDoc()) {
+		return
+	}
+
+	q.docs = make([]int32, len(points))
+	for i, p := range points {
+		q.docs[i] = p.docId
+	}
+	sort.Slice(q.docs, func(i, j int) bool {
+		return q.docs[i] < q.docs[j]
+	})
+}
+
+func (q *NumericRangeQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *NumericRangeQuery) Cost() int {
+	return q.count
+}
+
+func (q *NumericRangeQuery) Score() float32 {
+	return q.boost
+}
+
+func (q *NumericRangeQuery) UpperBound() float32 {
+	return q.boost
+}
+
+func (q *NumericRangeQuery) scan(target int32) int32 {
+	values := q.dv.values
+	for d := int(target); d < len(values); d++ {
+		if q.dv.exists[d] && values[d] >= q.lo && values[d] <= q.hi {
+			return int32(d)
+		}
+	}
+	return NO_MORE
+}
+
+func (q *NumericRangeQuery) Advance(target int32) int32 {
+	if target < 0 {
+		target = 0
+	}
+	if q.docId == NO_MORE || target == NO_MORE {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+	if target <= q.docId {
+		return q.docId
+	}
+
+	if q.docs == nil {
+		q.docId = q.scan(target)
+		return q.docId
+	}
+
+	left := q.docs[q.cursor:]
+	q.cursor += sort.Search(len(left), func(i int) bool {
+		return left[i] >= target
+	})
+	if q.cursor >= len(q.docs) {
+		q.docId = NO_MORE
+	} else {
+		q.docId = q.docs[q.cursor]
+	}
+	return q.docId
+}
+
+func (q *NumericRangeQuery) Next() int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.Advance(q.docId + 1)
+}
+
+func (q *NumericRangeQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, q.String())
+	}
+	return explainMatch(q.boost, "numeric range "+q.String()+", boost")
+}
+
+func (q *NumericRangeQuery) String() string {
+	if q.float {
+		return fmt.Sprintf("%s:[%g TO %g]", q.dv.Name(), sortableToFloat64(q.lo), sortableToFloat64(q.hi))
+	}
+	return fmt.Sprintf("%s:[%d TO %d]", q.dv.Name(), q.lo, q.hi)
+}
+
+func (q *NumericRangeQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+// nothing to decode, there is no payload
+func (q *NumericRangeQuery) PayloadDecode(p Payload) {}
+
+func (q *NumericRangeQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (q *NumericRangeQuery) Err() error {
+	return nil
+}
+
+// nothing to release
+func (q *NumericRangeQuery) Close() error {
+	return nil
+}
+
+func (q *NumericRangeQuery) Reset() {
+	q.docId = NOT_READY
+	q.cursor = 0
+}
+
+// The matching documents are shared
+func (q *NumericRangeQuery) Clone() Query {
+	c := *q
+	c.Reset()
+	return &c
+}
@@ -61,6 +61,30 @@ func (q *OrQuery) Score() float32 {
 	return score * q.boost
 }
 
+func (q *OrQuery) UpperBound() float32 {
+	sum := float32(0)
+	for _, s := range q.queries {
+		sum += s.UpperBound()
+	}
+	return boostUpperBound(sum, q.boost)
+}
+
+func (q *OrQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, "or")
+	}
+
+	details := []*Explanation{}
+	for _, s := range q.queries {
+		if s.GetDocId() == q.docId {
+			details = append(details, s.Explain())
+		} else {
+			details = append(details, explainNoMatch(s.String()))
+		}
+	}
+	return explainBoost(explainMatch(q.Score(), "or, sum of:", details...), q.boost)
+}
+
 func (q *OrQuery) Advance(target int32) int32 {
 	newDoc := NO_MORE
 	n := len(q.queries)
@@ -109,3 +133,20 @@ func (q *OrQuery) SetBoost(b float32) Query {
 	q.boost = b
 	return q
 }
+
+func (q *OrQuery) Err() error {
+	return firstErr(q.queries)
+}
+
+func (q *OrQuery) Close() error {
+	return closeAll(q.queries)
+}
+
+func (q *OrQuery) Reset() {
+	resetAll(q.queries)
+	q.docId = NOT_READY
+}
+
+func (q *OrQuery) Clone() Query {
+	return Or(cloneAll(q.queries)...).SetBoost(q.boost)
+}
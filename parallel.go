@@ -0,0 +1,130 @@
+package query
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// The document id space is split into workers*PARALLEL_RANGES_PER_WORKER
+// ranges, so a worker that got a sparse range takes the next one
+// instead of waiting for the others
+var PARALLEL_RANGES_PER_WORKER = 4
+
+// Collects the documents from..to (exclusive) of the query, the query
+// must not be past from, it is advanced to the range start
+func searchRange(q Query, c Collector, from, to int32) {
+	pruning, canPrune := q.(PruningQuery)
+	competitive, knowsMin := c.(CompetitiveCollector)
+	canPrune = canPrune && knowsMin
+
+	docId := q.GetDocId()
+	if docId < from {
+		docId = q.Advance(from)
+	}
+	for docId < to {
+		c.Collect(docId, q.Score())
+		if canPrune {
+			if min, ok := competitive.MinCompetitiveScore(); ok {
+				pruning.SetMinCompetitiveScore(min)
+			}
+		}
+		docId = q.Next()
+	}
+}
+
+// Splits the documents from 0 to maxDoc (exclusive) into ranges and
+// searches them with workers goroutines (GOMAXPROCS if workers <= 0),
+// each worker iterates its own Clone() of the query and collects into
+// its own collector created by newCollector, the ranges are taken in
+// increasing order, so a worker just advances its clone to the start
+// of the next range
+//
+// Returns the collectors of the workers, merge them to get the
+// result, see ParallelTopK(). The query itself is not iterated, the
+// clones are closed when done and the first error of them is returned
+func ParallelSearch(q Query, maxDoc int32, workers int, newCollector func() Collector) ([]Collector, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	n := int32(workers * PARALLEL_RANGES_PER_WORKER)
+	if n > maxDoc {
+		n = maxDoc
+	}
+	if n < 1 {
+		return nil, nil
+	}
+	size := maxDoc / n
+	if maxDoc%n != 0 {
+		size++
+	}
+
+	ranges := make(chan [2]int32, n)
+	for from := int32(0); from < maxDoc; {
+		to := maxDoc
+		if maxDoc-from > size {
+			to = from + size
+		}
+		ranges <- [2]int32{from, to}
+		from = to
+	}
+	close(ranges)
+	if workers > len(ranges) {
+		workers = len(ranges)
+	}
+
+	clones := make([]Query, workers)
+	collectors := make([]Collector, workers)
+	for i := range clones {
+		clones[i] = q.Clone()
+		collectors[i] = newCollector()
+	}
+
+	var wg sync.WaitGroup
+	for i := range clones {
+		wg.Add(1)
+		go func(q Query, c Collector) {
+			defer wg.Done()
+			for r := range ranges {
+				searchRange(q, c, r[0], r[1])
+			}
+		}(clones[i], collectors[i])
+	}
+	wg.Wait()
+
+	err := firstErr(clones)
+	if closeErr := closeAll(clones); err == nil {
+		err = closeErr
+	}
+	return collectors, err
+}
+
+// Parallel TopK(), searches the documents from 0 to maxDoc (exclusive)
+// with workers goroutines (see ParallelSearch) and merges their best
+// k hits and totals, the hits are the same as the ones of TopK()
+//
+// When the query prunes non competitive documents (see WAND) the
+// total is a lower bound, each worker prunes only with its own hits
+func ParallelTopK(q Query, k int, maxDoc int32, workers int) ([]Hit, int, error) {
+	collectors, err := ParallelSearch(q, maxDoc, workers, func() Collector {
+		return NewTopKCollector(k)
+	})
+
+	hits := []Hit{}
+	total := 0
+	for _, c := range collectors {
+		topk := c.(*TopKCollector)
+		hits = append(hits, topk.hits...)
+		total += topk.total
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hitBefore(hits[i], hits[j])
+	})
+	if k < 0 {
+		k = 0
+	}
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, total, err
+}
@@ -0,0 +1,84 @@
+package query
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParallelTopK(t *testing.T) {
+	n := 20000
+	idx := newSharedIndex(t, n)
+
+	for _, q := range idx.queries(n) {
+		expected, expectedTotal := TopK(q.Clone(), 10)
+		for _, workers := range []int{0, 1, 2, 3, 8, 100} {
+			for _, maxDoc := range []int32{int32(n), int32(n) + 12345, NO_MORE} {
+				hits, total, err := ParallelTopK(q, 10, maxDoc, workers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				eqHits(t, expected, hits)
+				if _, pruning := q.(PruningQuery); !pruning && total != expectedTotal {
+					t.Fatalf("%s: total %d != %d", q.String(), total, expectedTotal)
+				}
+			}
+		}
+		if q.GetDocId() != NOT_READY {
+			t.Fatalf("%s: iterated", q.String())
+		}
+	}
+
+	// documents after maxDoc are not searched
+	hits, total, _ := ParallelTopK(DocRange(0, 100), 1000, 50, 4)
+	if total != 50 || len(hits) != 50 {
+		t.Fatalf("%d %d", total, len(hits))
+	}
+	hits, total, _ = ParallelTopK(DocRange(0, 100), 1000, 0, 4)
+	if total != 0 || len(hits) != 0 {
+		t.Fatalf("%d %d", total, len(hits))
+	}
+	hits, total, _ = ParallelTopK(MatchAll(100), 0, 100, 4)
+	if total != 100 || len(hits) != 0 {
+		t.Fatalf("%d %d", total, len(hits))
+	}
+}
+
+func TestParallelSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := path.Join(dir, "postings")
+	if err := AppendFileNameTerm(fn, []int32{1, 5, 7, 100, 2000}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := FileTerm(10, fn)
+	defer q.Close()
+	collectors, err := ParallelSearch(q, 3000, 3, func() Collector {
+		return NewTopKCollector(10)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collectors) != 3 {
+		t.Fatalf("workers: %d", len(collectors))
+	}
+	total := 0
+	for _, c := range collectors {
+		total += c.(*TopKCollector).TotalHits()
+	}
+	if total != 5 {
+		t.Fatalf("total: %d", total)
+	}
+
+	// the clones can not open the file
+	os.Remove(fn)
+	_, _, err = ParallelTopK(q, 10, 3000, 3)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
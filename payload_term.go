@@ -22,11 +22,11 @@ func (t *PayloadTermQuery) GetDocId() int32 {
 }
 
 func (t *PayloadTermQuery) Cost() int {
-	return len(t.term.postings) - t.term.cursor
+	return len(t.term.data.postings) - t.term.cursor
 }
 
 func (t *PayloadTermQuery) String() string {
-	return fmt.Sprintf("p_%s(%d)/%.2f", t.term.term, len(t.term.postings), t.term.idf)
+	return fmt.Sprintf("p_%s(%d)/%.2f", t.term.data.term, len(t.term.data.postings), t.term.idf)
 }
 
 func (t *PayloadTermQuery) Score() float32 {
@@ -41,6 +41,23 @@ func (t *PayloadTermQuery) Next() int32 {
 	return t.term.Next()
 }
 
+func (t *PayloadTermQuery) UpperBound() float32 {
+	return t.term.UpperBound()
+}
+
+func (t *PayloadTermQuery) BlockUpperBound(target int32) (int32, float32) {
+	return t.term.BlockUpperBound(target)
+}
+
+func (t *PayloadTermQuery) Explain() *Explanation {
+	return t.term.Explain()
+}
+
+func (t *PayloadTermQuery) SetSimilarity(s Similarity) *PayloadTermQuery {
+	t.term.SetSimilarity(s)
+	return t
+}
+
 func (t *PayloadTermQuery) SetBoost(b float32) Query {
 	t.term.SetBoost(b)
 	return t
@@ -56,3 +73,22 @@ func (t *PayloadTermQuery) PayloadDecode(p Payload) {
 func (t *PayloadTermQuery) AddSubQuery(Query) Query {
 	panic("unsupported")
 }
+
+func (t *PayloadTermQuery) Err() error {
+	return t.term.Err()
+}
+
+func (t *PayloadTermQuery) Close() error {
+	return t.term.Close()
+}
+
+func (t *PayloadTermQuery) Reset() {
+	t.term.Reset()
+}
+
+func (t *PayloadTermQuery) Clone() Query {
+	return &PayloadTermQuery{
+		term:    t.term.Clone().(*TermQuery),
+		payload: t.payload,
+	}
+}
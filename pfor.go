@@ -0,0 +1,363 @@
+package query
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Number of documents in each compressed postings block
+const POSTINGS_BLOCK_SIZE = 128
+
+var errCorruptPostings = errors.New("corrupt compressed postings")
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// Patched frame of reference: picks the bit width that makes the block
+// smallest, values that do not fit are stored as exceptions
+//
+//	width:      byte
+//	exceptions: byte
+//	packed:     ceil(len(values) * width / 8) bytes, the low bits of each value
+//	exceptions: position byte and uvarint of the high bits, for each exception
+func pforEncode(dst []byte, values []uint32) []byte {
+	var counts [33]int
+	for _, v := range values {
+		counts[bits.Len32(v)]++
+	}
+
+	width := 32
+	best := -1
+	for b := 0; b <= 32; b++ {
+		size := (len(values)*b + 7) / 8
+		exceptions := 0
+		for i := b + 1; i <= 32; i++ {
+			exceptions += counts[i]
+		}
+		if exceptions > 0 {
+			for _, v := range values {
+				if bits.Len32(v) > b {
+					size += 1 + uvarintLen(uint64(v)>>uint(b))
+				}
+			}
+		}
+		if best < 0 || size < best {
+			best = size
+			width = b
+		}
+	}
+
+	dst = append(dst, byte(width))
+	exceptionsAt := len(dst)
+	dst = append(dst, 0)
+
+	mask := uint64(1)<<uint(width) - 1
+	acc := uint64(0)
+	n := uint(0)
+	for _, v := range values {
+		acc |= (uint64(v) & mask) << n
+		n += uint(width)
+		for n >= 8 {
+			dst = append(dst, byte(acc))
+			acc >>= 8
+			n -= 8
+		}
+	}
+	if n > 0 {
+		dst = append(dst, byte(acc))
+	}
+
+	exceptions := 0
+	for i, v := range values {
+		if bits.Len32(v) > width {
+			dst = append(dst, byte(i))
+			dst = appendUvarint(dst, uint64(v)>>uint(width))
+			exceptions++
+		}
+	}
+	dst[exceptionsAt] = byte(exceptions)
+	return dst
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return append(dst, b[:binary.PutUvarint(b, v)]...)
+}
+
+// Decodes len(out) values, returns the number of bytes read
+func pforDecode(src []byte, out []uint32) (int, error) {
+	if len(src) < 2 || src[0] > 32 {
+		return 0, errCorruptPostings
+	}
+	width := uint(src[0])
+	exceptions := int(src[1])
+	pos := 2
+	packed := (len(out)*int(width) + 7) / 8
+	if len(src) < pos+packed {
+		return 0, errCorruptPostings
+	}
+
+	mask := uint64(1)<<width - 1
+	acc := uint64(0)
+	n := uint(0)
+	for i := range out {
+		for n < width {
+			acc |= uint64(src[pos]) << n
+			pos++
+			n += 8
+		}
+		out[i] = uint32(acc & mask)
+		acc >>= width
+		n -= width
+	}
+
+	for i := 0; i < exceptions; i++ {
+		if pos >= len(src) || int(src[pos]) >= len(out) {
+			return 0, errCorruptPostings
+		}
+		idx := src[pos]
+		high, k := binary.Uvarint(src[pos+1:])
+		if k <= 0 {
+			return 0, errCorruptPostings
+		}
+		out[idx] |= uint32(high << width)
+		pos += 1 + k
+	}
+	return pos, nil
+}
+
+type postingsBlock struct {
+	maxDoc int32
+	offset int
+	count  int
+}
+
+// Compresses sorted postings list, the documents are split in blocks
+// of POSTINGS_BLOCK_SIZE, each block stores the deltas between the
+// documents with patched frame of reference bit packing
+//
+//	count:  uvarint
+//	skip:   for each block, uvarint of the max document delta and of the block size in bytes
+//	blocks: pfor encoded deltas
+func EncodePostings(postings []int32) ([]byte, error) {
+	out := appendUvarint(nil, uint64(len(postings)))
+	blocks := []byte{}
+	values := make([]uint32, 0, POSTINGS_BLOCK_SIZE)
+
+	prev := int32(-1)
+	for start := 0; start < len(postings); start += POSTINGS_BLOCK_SIZE {
+		end := start + POSTINGS_BLOCK_SIZE
+		if end > len(postings) {
+			end = len(postings)
+		}
+		prevMax := prev
+		values = values[:0]
+		for _, p := range postings[start:end] {
+			if p < prev || p < 0 {
+				return nil, fmt.Errorf("postings are not sorted, %d after %d", p, prev)
+			}
+			values = append(values, uint32(p-prev))
+			prev = p
+		}
+
+		size := len(blocks)
+		blocks = pforEncode(blocks, values)
+		out = appendUvarint(out, uint64(prev-prevMax))
+		out = appendUvarint(out, uint64(len(blocks)-size))
+	}
+	return append(out, blocks...), nil
+}
+
+// returns the number of documents and the skip table
+func decodePostingsHeader(data []byte) (int, []postingsBlock, error) {
+	count, pos := binary.Uvarint(data)
+	if pos <= 0 || count > uint64(len(data))*8 {
+		return 0, nil, errCorruptPostings
+	}
+	n := int(count)
+	blocks := make([]postingsBlock, (n+POSTINGS_BLOCK_SIZE-1)/POSTINGS_BLOCK_SIZE)
+	maxDoc := int64(-1)
+	offset := 0
+	for i := range blocks {
+		delta, k := binary.Uvarint(data[pos:])
+		if k <= 0 {
+			return 0, nil, errCorruptPostings
+		}
+		pos += k
+		size, k := binary.Uvarint(data[pos:])
+		if k <= 0 {
+			return 0, nil, errCorruptPostings
+		}
+		pos += k
+
+		maxDoc += int64(delta)
+		if maxDoc > int64(NO_MORE) || size > uint64(len(data)) {
+			return 0, nil, errCorruptPostings
+		}
+		count := POSTINGS_BLOCK_SIZE
+		if i == len(blocks)-1 {
+			count = n - i*POSTINGS_BLOCK_SIZE
+		}
+		blocks[i] = postingsBlock{maxDoc: int32(maxDoc), offset: offset, count: count}
+		offset += int(size)
+	}
+	for i := range blocks {
+		blocks[i].offset += pos
+	}
+	if pos+offset != len(data) {
+		return 0, nil, errCorruptPostings
+	}
+	return n, blocks, nil
+}
+
+// decodes the block into out, prev is the max document of the
+// previous block
+func decodePostingsBlock(data []byte, b postingsBlock, prev int32, out []int32) ([]int32, error) {
+	var deltas [POSTINGS_BLOCK_SIZE]uint32
+	if _, err := pforDecode(data[b.offset:], deltas[:b.count]); err != nil {
+		return nil, err
+	}
+	out = out[:0]
+	for _, d := range deltas[:b.count] {
+		prev += int32(d)
+		out = append(out, prev)
+	}
+	if len(out) > 0 && out[len(out)-1] != b.maxDoc {
+		return nil, errCorruptPostings
+	}
+	return out, nil
+}
+
+// Decompresses postings list created with EncodePostings
+func DecodePostings(data []byte) ([]int32, error) {
+	n, blocks, err := decodePostingsHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int32, 0, n)
+	prev := int32(-1)
+	for _, b := range blocks {
+		decoded, err := decodePostingsBlock(data, b, prev, out[len(out):])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decoded...)
+		prev = b.maxDoc
+	}
+	return out, nil
+}
+
+type intsBlock struct {
+	max    int32
+	offset int
+	count  int
+}
+
+// Compresses values (e.g. term frequencies) in blocks of
+// POSTINGS_BLOCK_SIZE without delta encoding, the skip table has the
+// max value of each block, so it is known without decoding the block
+//
+//	count:  uvarint
+//	skip:   for each block, uvarint of the max value and of the block size in bytes
+//	blocks: pfor encoded values
+func encodeInts(values []int32) []byte {
+	out := appendUvarint(nil, uint64(len(values)))
+	blocks := []byte{}
+	block := make([]uint32, 0, POSTINGS_BLOCK_SIZE)
+	for start := 0; start < len(values); start += POSTINGS_BLOCK_SIZE {
+		end := start + POSTINGS_BLOCK_SIZE
+		if end > len(values) {
+			end = len(values)
+		}
+		block = block[:0]
+		max := uint32(0)
+		for _, v := range values[start:end] {
+			block = append(block, uint32(v))
+			if uint32(v) > max {
+				max = uint32(v)
+			}
+		}
+		size := len(blocks)
+		blocks = pforEncode(blocks, block)
+		out = appendUvarint(out, uint64(max))
+		out = appendUvarint(out, uint64(len(blocks)-size))
+	}
+	return append(out, blocks...)
+}
+
+// returns the number of values and the skip table
+func decodeIntsHeader(data []byte) (int, []intsBlock, error) {
+	count, pos := binary.Uvarint(data)
+	if pos <= 0 || count > uint64(len(data))*8 {
+		return 0, nil, errCorruptPostings
+	}
+	n := int(count)
+	blocks := make([]intsBlock, (n+POSTINGS_BLOCK_SIZE-1)/POSTINGS_BLOCK_SIZE)
+	offset := 0
+	for i := range blocks {
+		max, k := binary.Uvarint(data[pos:])
+		if k <= 0 || max > uint64(NO_MORE) {
+			return 0, nil, errCorruptPostings
+		}
+		pos += k
+		size, k := binary.Uvarint(data[pos:])
+		if k <= 0 || size > uint64(len(data)) {
+			return 0, nil, errCorruptPostings
+		}
+		pos += k
+
+		count := POSTINGS_BLOCK_SIZE
+		if i == len(blocks)-1 {
+			count = n - i*POSTINGS_BLOCK_SIZE
+		}
+		blocks[i] = intsBlock{max: int32(max), offset: offset, count: count}
+		offset += int(size)
+	}
+	for i := range blocks {
+		blocks[i].offset += pos
+	}
+	if pos+offset != len(data) {
+		return 0, nil, errCorruptPostings
+	}
+	return n, blocks, nil
+}
+
+// decodes the block into out
+func decodeIntsBlock(data []byte, b intsBlock, out []int32) ([]int32, error) {
+	var values [POSTINGS_BLOCK_SIZE]uint32
+	if _, err := pforDecode(data[b.offset:], values[:b.count]); err != nil {
+		return nil, err
+	}
+	out = out[:0]
+	for _, v := range values[:b.count] {
+		if v > uint32(b.max) {
+			return nil, errCorruptPostings
+		}
+		out = append(out, int32(v))
+	}
+	return out, nil
+}
+
+func decodeInts(data []byte) ([]int32, error) {
+	n, blocks, err := decodeIntsHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int32, 0, n)
+	for _, b := range blocks {
+		decoded, err := decodeIntsBlock(data, b, out[len(out):])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decoded...)
+	}
+	return out, nil
+}
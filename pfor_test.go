@@ -0,0 +1,240 @@
+package query
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPFOR(t *testing.T) {
+	rand.Seed(0)
+	for _, n := range []int{0, 1, 5, 127, 128, 129, 1000} {
+		for _, max := range []int{1, 2, 100, 1 << 20, math.MaxInt32} {
+			values := make([]uint32, n)
+			for i := range values {
+				values[i] = uint32(rand.Intn(max))
+				if rand.Intn(50) == 0 {
+					values[i] = uint32(rand.Int31())
+				}
+			}
+			for start := 0; start < n; start += POSTINGS_BLOCK_SIZE {
+				end := start + POSTINGS_BLOCK_SIZE
+				if end > n {
+					end = n
+				}
+				encoded := pforEncode([]byte{9}, values[start:end])
+				decoded := make([]uint32, end-start)
+				read, err := pforDecode(encoded[1:], decoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if read != len(encoded)-1 {
+					t.Fatalf("read %d of %d", read, len(encoded)-1)
+				}
+				for i, v := range decoded {
+					if v != values[start+i] {
+						t.Fatalf("n: %d, max: %d, %d != %d", n, max, v, values[start+i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncodePostings(t *testing.T) {
+	rand.Seed(0)
+	for _, n := range []int{0, 1, 127, 128, 129, 256, 10000} {
+		postings := uniquePostingsList(n)
+		encoded, err := EncodePostings(postings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodePostings(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq(t, postings, decoded)
+	}
+
+	// dense postings take less than a byte per document
+	dense := []int32{}
+	for i := int32(0); i < 100000; i += 1 + int32(rand.Intn(5)) {
+		dense = append(dense, i)
+	}
+	encoded, _ := EncodePostings(dense)
+	if len(encoded) > len(dense) {
+		t.Fatalf("%d bytes for %d documents", len(encoded), len(dense))
+	}
+
+	eq(t, []int32{0, 0, 5, math.MaxInt32 - 1}, mustDecode(t, []int32{0, 0, 5, math.MaxInt32 - 1}))
+
+	if _, err := EncodePostings([]int32{1, 3, 2}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := EncodePostings([]int32{-1}); err == nil {
+		t.Fatal("expected error")
+	}
+	encoded, _ = EncodePostings(uniquePostingsList(300))
+	for i := 0; i < len(encoded); i++ {
+		if _, err := DecodePostings(encoded[:i]); err == nil {
+			t.Fatalf("truncated at %d but no error", i)
+		}
+	}
+
+	ints := []int32{1, 1, 3, 1, 200, 1, 1}
+	decoded, err := decodeInts(encodeInts(ints))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, ints, decoded)
+
+	// the max of each block is in the skip table
+	ints = []int32{}
+	for i := 0; i < 300; i++ {
+		ints = append(ints, int32(1+i%50))
+	}
+	ints[200] = 1000
+	encoded = encodeInts(ints)
+	n, blocks, err := decodeIntsHeader(encoded)
+	if err != nil || n != 300 || len(blocks) != 3 || blocks[0].max != 50 || blocks[1].max != 1000 {
+		t.Fatalf("header %d %v %v", n, blocks, err)
+	}
+	decoded, _ = decodeInts(encoded)
+	eq(t, ints, decoded)
+	for i := 0; i < len(encoded); i++ {
+		if _, err := decodeInts(encoded[:i]); err == nil {
+			t.Fatalf("truncated at %d but no error", i)
+		}
+	}
+}
+
+func mustDecode(t *testing.T, postings []int32) []int32 {
+	encoded, err := EncodePostings(postings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodePostings(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestCompressedTerm(t *testing.T) {
+	rand.Seed(0)
+	compressed := func(postings []int32) *CompressedTermQuery {
+		encoded, err := EncodePostings(postings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return CompressedTerm(1000000, "x", encoded)
+	}
+
+	for _, n := range []int{0, 1, 128, 129, 5000} {
+		postings := uniquePostingsList(n)
+		eq(t, postings, query(compressed(postings)))
+		eqF(t, queryScores(Term(1000000, "x", postings)), queryScores(compressed(postings)))
+
+		// same as term with random advances
+		for k := 0; k < 20; k++ {
+			a, b := Term(1000000, "x", postings), compressed(postings)
+			for a.GetDocId() != NO_MORE {
+				var da, db int32
+				if rand.Intn(2) == 0 {
+					da, db = a.Next(), b.Next()
+				} else {
+					target := a.GetDocId() + int32(rand.Intn(100000))
+					da, db = a.Advance(target), b.Advance(target)
+				}
+				if da != db {
+					t.Fatalf("%d != %d", da, db)
+				}
+				if da != NO_MORE && a.Cost() != b.Cost() {
+					t.Fatalf("cost %d != %d", a.Cost(), b.Cost())
+				}
+			}
+		}
+	}
+
+	postings := uniquePostingsList(1000)
+	eq(t, postings[1:], query(And(compressed(postings), Term(1000000, "y", postings[1:]))))
+	hits, _ := TopK(WAND(compressed(postings), Term(1000000, "y", postings[:500])), 10)
+	expected, _ := TopK(Or(Term(1000000, "x", postings), Term(1000000, "y", postings[:500])), 10)
+	eqHits(t, expected, hits)
+	if !isMatchNone(Rewrite(compressed([]int32{}))) {
+		t.Fatal("rewrite")
+	}
+}
+
+func TestCompressedTermCorrupt(t *testing.T) {
+	postings := uniquePostingsList(1000)
+	freqs := make([]int32, len(postings))
+	for i := range freqs {
+		freqs[i] = int32(1 + i%7)
+	}
+	encoded, err := EncodePostings(postings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedFreqs := encodeInts(freqs)
+
+	if _, err := OpenCompressedTerm(1000, "x", encoded[:1]); err == nil {
+		t.Fatal("expected header error")
+	}
+	if _, err := compressedTerm(1000, "x", encoded, encodedFreqs[:1]); err == nil {
+		t.Fatal("expected frequencies header error")
+	}
+
+	// the corrupt block stops the iteration and is returned by Err()
+	iterate := func(q Query) error {
+		for q.Next() != NO_MORE {
+			q.Score()
+		}
+		if q.Advance(0) != NO_MORE {
+			t.Fatal("advance after the end")
+		}
+		return q.Err()
+	}
+	flipped := func(data []byte, i int) []byte {
+		c := make([]byte, len(data))
+		copy(c, data)
+		c[i] ^= 0x10
+		return c
+	}
+	corrupt := 0
+	for i := range encoded {
+		c := flipped(encoded, i)
+		if _, err := DecodePostings(c); err == nil {
+			continue
+		}
+		q, err := OpenCompressedTerm(1000, "x", c)
+		if err != nil {
+			continue
+		}
+		corrupt++
+		if iterate(q) == nil {
+			t.Fatalf("byte %d flipped but no error", i)
+		}
+		q.Reset()
+		if iterate(q) == nil {
+			t.Fatalf("byte %d flipped but no error after reset", i)
+		}
+	}
+	for i := range encodedFreqs {
+		c := flipped(encodedFreqs, i)
+		if _, err := decodeInts(c); err == nil {
+			continue
+		}
+		q, err := compressedTerm(1000, "x", encoded, c)
+		if err != nil {
+			continue
+		}
+		corrupt++
+		if iterate(q) == nil {
+			t.Fatalf("frequency byte %d flipped but no error", i)
+		}
+	}
+	if corrupt == 0 {
+		t.Fatal("no corrupt blocks")
+	}
+}
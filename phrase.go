@@ -0,0 +1,187 @@
+package query
+
+import "strings"
+
+type PhraseQuery struct {
+	terms []PositionalQuery
+	conj  *AndQuery
+	docId int32
+	freq  int
+	boost float32
+}
+
+// Creates exact phrase query, matches only documents in which the
+// terms appear consecutively and in the given order, e.g.
+//
+//	Phrase(new, york)
+//
+// matches {new york city} but not {york new} or {new big york}
+//
+// The score is the sum of the terms scores multiplied by the number of
+// times the phrase appears in the document.
+func Phrase(terms ...PositionalQuery) *PhraseQuery {
+	queries := make([]Query, len(terms))
+	for i, t := range terms {
+		queries[i] = t
+	}
+
+	return &PhraseQuery{
+		terms: terms,
+		conj:  And(queries...),
+		docId: NOT_READY,
+		boost: 1,
+	}
+}
+
+// Returns how many times the phrase appears in the current document,
+// expects all terms to be on the same document
+func (q *PhraseQuery) phraseFreq() int {
+	first := q.terms[0].Positions()
+	if len(q.terms) == 1 {
+		return len(first)
+	}
+
+	rest := make([][]int32, len(q.terms)-1)
+	cursors := make([]int, len(rest))
+	for i := range rest {
+		rest[i] = q.terms[i+1].Positions()
+	}
+
+	freq := 0
+NEXT:
+	for _, start := range first {
+		for i, positions := range rest {
+			want := start + int32(i+1)
+			for cursors[i] < len(positions) && positions[cursors[i]] < want {
+				cursors[i]++
+			}
+			if cursors[i] == len(positions) {
+				break NEXT
+			}
+			if positions[cursors[i]] != want {
+				continue NEXT
+			}
+		}
+		freq++
+	}
+	return freq
+}
+
+func (q *PhraseQuery) nextPhrase(target int32) int32 {
+	for target != NO_MORE {
+		q.freq = q.phraseFreq()
+		if q.freq > 0 {
+			break
+		}
+		target = q.conj.Next()
+	}
+	q.docId = target
+	return target
+}
+
+func (q *PhraseQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *PhraseQuery) Cost() int {
+	return q.conj.Cost()
+}
+
+func (q *PhraseQuery) Score() float32 {
+	score := float32(0)
+	for _, t := range q.terms {
+		score += t.Score()
+	}
+	return score * float32(q.freq) * q.boost
+}
+
+// The phrase frequency is not known upfront
+func (q *PhraseQuery) UpperBound() float32 {
+	if len(q.terms) == 0 {
+		return 0
+	}
+	return boostUpperBound(unknownUpperBound, q.boost)
+}
+
+func (q *PhraseQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE || len(q.terms) == 0 {
+		return explainNotReady(q.docId, "phrase")
+	}
+
+	details := []*Explanation{}
+	for _, t := range q.terms {
+		details = append(details, t.Explain())
+	}
+	details = append(details, explainMatch(float32(q.freq), "phrase freq"))
+	return explainBoost(explainMatch(q.Score(), "phrase, sum of terms * phrase freq:", details...), q.boost)
+}
+
+func (q *PhraseQuery) Advance(target int32) int32 {
+	if len(q.terms) == 0 {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+	return q.nextPhrase(q.conj.Advance(target))
+}
+
+func (q *PhraseQuery) Next() int32 {
+	if len(q.terms) == 0 {
+		q.docId = NO_MORE
+		return NO_MORE
+	}
+	return q.nextPhrase(q.conj.Next())
+}
+
+func (q *PhraseQuery) String() string {
+	out := []string{}
+	for _, v := range q.terms {
+		out = append(out, v.String())
+	}
+	return "{\"" + strings.Join(out, " ") + "\"}"
+}
+
+func (q *PhraseQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+func (q *PhraseQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (q *PhraseQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (q *PhraseQuery) Err() error {
+	for _, t := range q.terms {
+		if err := t.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *PhraseQuery) Close() error {
+	var first error
+	for _, t := range q.terms {
+		if err := t.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (q *PhraseQuery) Reset() {
+	q.conj.Reset()
+	q.docId = NOT_READY
+	q.freq = 0
+}
+
+func (q *PhraseQuery) Clone() Query {
+	terms := make([]PositionalQuery, len(q.terms))
+	for i, t := range q.terms {
+		terms[i] = t.Clone().(PositionalQuery)
+	}
+	return Phrase(terms...).SetBoost(q.boost)
+}
@@ -0,0 +1,150 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+type positionalIndex struct {
+	n         int
+	postings  map[string][]int32
+	positions map[string][][]int32
+}
+
+func newPositionalIndex(docs ...string) *positionalIndex {
+	idx := &positionalIndex{
+		n:         len(docs),
+		postings:  map[string][]int32{},
+		positions: map[string][][]int32{},
+	}
+	for did, d := range docs {
+		seen := map[string]bool{}
+		for pos, token := range strings.Fields(d) {
+			if !seen[token] {
+				seen[token] = true
+				idx.postings[token] = append(idx.postings[token], int32(did))
+				idx.positions[token] = append(idx.positions[token], []int32{})
+			}
+			last := len(idx.positions[token]) - 1
+			idx.positions[token][last] = append(idx.positions[token][last], int32(pos))
+		}
+	}
+	return idx
+}
+
+func (idx *positionalIndex) term(t string) *PositionalTermQuery {
+	return PositionalTerm(idx.n, t, idx.postings[t], idx.positions[t])
+}
+
+func (idx *positionalIndex) terms(s string) []PositionalQuery {
+	out := []PositionalQuery{}
+	for _, t := range strings.Fields(s) {
+		out = append(out, idx.term(t))
+	}
+	return out
+}
+
+func TestPhrase(t *testing.T) {
+	idx := newPositionalIndex(
+		"new york city",
+		"york new",
+		"new big york",
+		"new york new york",
+		"old york",
+		"the new york times",
+	)
+
+	eq(t, []int32{0, 3, 5}, query(Phrase(idx.terms("new york")...)))
+	eq(t, []int32{1, 3}, query(Phrase(idx.terms("york new")...)))
+	eq(t, []int32{3}, query(Phrase(idx.terms("new york new york")...)))
+	eq(t, []int32{5}, query(Phrase(idx.terms("the new york times")...)))
+	eq(t, []int32{0, 1, 2, 3, 5}, query(Phrase(idx.terms("new")...)))
+	eq(t, []int32{}, query(Phrase(idx.terms("new york old")...)))
+	eq(t, []int32{}, query(Phrase(idx.terms("missing york")...)))
+	eq(t, []int32{}, query(Phrase()))
+
+	p := Phrase(idx.terms("new york")...)
+	if p.Advance(1) != 3 {
+		t.Fatal("advance")
+	}
+	if p.Advance(4) != 5 {
+		t.Fatal("advance")
+	}
+
+	single := idx.term("new").Score() + idx.term("york").Score()
+	eqF(t, []float32{single, 2 * single, single}, queryScores(Phrase(idx.terms("new york")...)))
+
+	eq(t, []int32{0, 5}, query(And(
+		Phrase(idx.terms("new york")...),
+		Or(idx.term("city"), idx.term("times")),
+	)))
+
+	eq(t, []int32{3}, query(AndNot(
+		Or(idx.term("city"), idx.term("times")),
+		Phrase(idx.terms("new york")...),
+	)))
+
+	eq(t, []int32{0, 2, 3, 5}, query(Or(
+		Phrase(idx.terms("new york")...),
+		Phrase(idx.terms("big york")...),
+	)))
+
+	eq(t, []int32{0, 3, 4, 5}, query(DisMax(0.1,
+		Phrase(idx.terms("new york")...),
+		idx.term("old"),
+	)))
+
+	if !strings.Contains(Phrase(idx.terms("new york")...).String(), "\"new/") {
+		t.Fatal("string")
+	}
+}
+
+func TestNear(t *testing.T) {
+	idx := newPositionalIndex(
+		"new york city",
+		"york new",
+		"new big york",
+		"new very big york",
+		"york is not new",
+		"old york",
+	)
+
+	eq(t, []int32{0}, query(Near(0, true, idx.terms("new york")...)))
+	eq(t, []int32{0, 2}, query(Near(1, true, idx.terms("new york")...)))
+	eq(t, []int32{0, 2, 3}, query(Near(2, true, idx.terms("new york")...)))
+	eq(t, []int32{1}, query(Near(0, true, idx.terms("york new")...)))
+	eq(t, []int32{0, 1}, query(Near(0, false, idx.terms("new york")...)))
+	eq(t, []int32{0, 1, 2}, query(Near(1, false, idx.terms("york new")...)))
+	eq(t, []int32{0, 1, 2, 3, 4}, query(Near(2, false, idx.terms("york new")...)))
+	eq(t, []int32{2, 3}, query(Near(2, false, idx.terms("york big new")...)))
+	eq(t, []int32{}, query(Near(10, false, idx.terms("old new")...)))
+	eq(t, []int32{}, query(Near(1, false)))
+
+	// repeated term needs its own position
+	repeated := newPositionalIndex("new york", "new new york", "new big new")
+	eq(t, []int32{1}, query(Near(0, false, repeated.terms("new new")...)))
+	eq(t, []int32{1}, query(Near(0, true, repeated.terms("new new")...)))
+	eq(t, []int32{1}, query(Phrase(repeated.terms("new new")...)))
+	eq(t, []int32{1, 2}, query(Near(1, false, repeated.terms("new new")...)))
+	eq(t, []int32{1}, query(Near(1, false, repeated.terms("new new york")...)))
+	eq(t, []int32{}, query(Near(5, false, repeated.terms("new new new")...)))
+
+	eqF(t,
+		queryScores(Phrase(idx.terms("new york")...)),
+		queryScores(Near(0, true, idx.terms("new york")...)),
+	)
+
+	single := idx.term("new").Score() + idx.term("york").Score()
+	eqF(t, []float32{single, single / 2, single / 3}, queryScores(Near(2, true, idx.terms("new york")...)))
+
+	ladder := DisMax(0,
+		Phrase(idx.terms("new york")...).SetBoost(3),
+		Near(2, false, idx.terms("new york")...).SetBoost(2),
+		And(idx.term("new"), idx.term("york")),
+	)
+	eqF(t, []float32{3 * single, 2 * single, single, single, single}, queryScores(ladder))
+
+	if !strings.Contains(Near(2, false, idx.terms("new york")...).String(), "~2") {
+		t.Fatal("string")
+	}
+}
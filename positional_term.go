@@ -0,0 +1,113 @@
+package query
+
+import (
+	"fmt"
+)
+
+// Query that can tell where inside the current document it matched
+type PositionalQuery interface {
+	Query
+	// Positions of the current document, sorted in ascending order
+	Positions() []int32
+}
+
+type PositionalTermQuery struct {
+	term      *TermQuery
+	positions [][]int32
+}
+
+// Creates term query that also knows the positions of the term inside
+// each document, positions[i] must be the sorted positions of the
+// term in document postings[i], e.g. for documents {new york} and
+// {new york new york} the term "new" has postings []int32{0,1} and
+// positions [][]int32{{0},{0,2}}
+//
+// Scores the same way as Term(), the positions are used by Phrase()
+//
+// use Reset() to iterate it again, Clone() for another goroutine (see Query)
+func PositionalTerm(totalDocumentsInIndex int, t string, postings []int32, positions [][]int32) *PositionalTermQuery {
+	return &PositionalTermQuery{
+		term:      Term(totalDocumentsInIndex, t, postings),
+		positions: positions,
+	}
+}
+
+func (t *PositionalTermQuery) Positions() []int32 {
+	if t.term.docId == NOT_READY || t.term.docId == NO_MORE {
+		return nil
+	}
+	return t.positions[t.term.cursor]
+}
+
+func (t *PositionalTermQuery) GetDocId() int32 {
+	return t.term.docId
+}
+
+func (t *PositionalTermQuery) Cost() int {
+	return t.term.Cost()
+}
+
+func (t *PositionalTermQuery) String() string {
+	return fmt.Sprintf("%s/%.2f", t.term.data.term, t.term.idf)
+}
+
+func (t *PositionalTermQuery) Score() float32 {
+	return t.term.Score()
+}
+
+func (t *PositionalTermQuery) Advance(target int32) int32 {
+	return t.term.Advance(target)
+}
+
+func (t *PositionalTermQuery) Next() int32 {
+	return t.term.Next()
+}
+
+func (t *PositionalTermQuery) UpperBound() float32 {
+	return t.term.UpperBound()
+}
+
+func (t *PositionalTermQuery) BlockUpperBound(target int32) (int32, float32) {
+	return t.term.BlockUpperBound(target)
+}
+
+func (t *PositionalTermQuery) Explain() *Explanation {
+	return t.term.Explain()
+}
+
+func (t *PositionalTermQuery) SetSimilarity(s Similarity) *PositionalTermQuery {
+	t.term.SetSimilarity(s)
+	return t
+}
+
+func (t *PositionalTermQuery) SetBoost(b float32) Query {
+	t.term.SetBoost(b)
+	return t
+}
+
+func (t *PositionalTermQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (t *PositionalTermQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (t *PositionalTermQuery) Err() error {
+	return t.term.Err()
+}
+
+func (t *PositionalTermQuery) Close() error {
+	return t.term.Close()
+}
+
+func (t *PositionalTermQuery) Reset() {
+	t.term.Reset()
+}
+
+func (t *PositionalTermQuery) Clone() Query {
+	return &PositionalTermQuery{
+		term:      t.term.Clone().(*TermQuery),
+		positions: t.positions,
+	}
+}
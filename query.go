@@ -10,10 +10,29 @@ const (
 	NOT_READY = int32(-1)
 )
 
+// Resources:
+// Queries over files (e.g. FileTerm) keep the file open until they are
+// exhausted, AND stops as soon as one of its queries is exhausted, so
+// always Close() the query when done with it:
+//
+//  q := And(FileTerm(n, "a"), FileTerm(n, "b"))
+//  defer q.Close()
+//
 // Reuse/Concurrency:
-// None of the queries are safe to be re-used.
-// WARNING: the query *can not* be reused
-// WARNING: the query it not thread safe
+// Use Reset() to iterate the same query again and Clone() to get
+// independent copy that shares the postings, e.g. to run cached query
+// plan many times or from many goroutines.
+// WARNING: the query is not thread safe, one goroutine per clone
+//
+// The index data is immutable and safe to share between goroutines:
+// TermData, TermTFData, TermDictionary, SegmentReader, Bitmap,
+// DocValues (after Freeze()), and FilterCache is safe as well,
+// so load it once and let each goroutine build its own queries:
+//
+//  data := NewTermData(n, "name:amsterdam", postings) // once
+//  go func() {
+//  	hits, _ := TopK(And(data.Query(), dict.Term("country:nl")), 10)
+//  }()
 //
 // Example Iteration:
 //
@@ -34,6 +53,67 @@ type Query interface {
 	AddSubQuery(Query) Query
 
 	PayloadDecode(p Payload)
+
+	// Explains the score of the current document
+	Explain() *Explanation
+
+	// Maximum score the query can produce for any document, +Inf if
+	// it can not be computed cheaply
+	UpperBound() float32
+
+	// Returns the error that stopped the iteration early (e.g. failed
+	// read of FileTerm), composite queries return the first error of
+	// their sub queries, check it after the iteration returns NO_MORE
+	Err() error
+
+	// Releases the resources (e.g. open files) of the query and all
+	// its sub queries, it is safe to call it many times, the query
+	// can not be used after that
+	Close() error
+
+	// Rewinds the query and all its sub queries to NOT_READY, so it
+	// can be iterated again
+	Reset()
+
+	// Returns query with the same structure that iterates
+	// independently from this one (e.g. in another goroutine), the
+	// immutable data like the postings and the block index is shared
+	Clone() Query
+}
+
+func resetAll(queries []Query) {
+	for _, q := range queries {
+		q.Reset()
+	}
+}
+
+func cloneAll(queries []Query) []Query {
+	out := make([]Query, len(queries))
+	for i, q := range queries {
+		out[i] = q.Clone()
+	}
+	return out
+}
+
+// closes all the queries, returns the first error
+func closeAll(queries []Query) error {
+	var first error
+	for _, q := range queries {
+		if err := q.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// returns the first error of the queries
+func firstErr(queries []Query) error {
+	for _, q := range queries {
+		if err := q.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Payload interface {
@@ -505,3 +505,265 @@ func TestModify(t *testing.T) {
 
 	eq(t, []int32{1, 2, 3, 9}, query(q))
 }
+
+func TestMultipleNot(t *testing.T) {
+	eq(t, []int32{2, 6}, query(AndNot(
+		Term(10, "x", []int32{1, 3}),
+		Term(10, "y", []int32{1, 2, 3, 4, 5, 6}),
+	).AddNot(Term(10, "z", []int32{4, 5}))))
+
+	eq(t, []int32{2, 5, 6}, query(And(
+		Term(10, "y", []int32{1, 2, 3, 4, 5, 6}),
+	).AddNot(Term(10, "x", []int32{1, 3}), nil, Or(Term(10, "z", []int32{4}), Term(10, "w", []int32{}))).SetNot(nil).AddNot(
+		Term(10, "x", []int32{1, 3, 4}),
+	)))
+
+	eq(t, []int32{0, 2, 5, 7, 8, 9}, query(Not(10,
+		Term(10, "x", []int32{1, 3}),
+		Term(10, "z", []int32{4, 6, 11}),
+	)))
+	eq(t, []int32{0, 1, 2}, query(Not(3)))
+	eq(t, []int32{}, query(Not(0)))
+
+	eq(t, []int32{5}, query(And(
+		Term(10, "y", []int32{1, 2, 3, 5}),
+		Not(10, Term(10, "x", []int32{1, 3}), Term(10, "x", []int32{0, 2, 7})),
+	)))
+
+	s := AndNot(Term(10, "x", []int32{1}), Term(10, "y", []int32{2})).AddNot(Term(10, "z", []int32{1})).String()
+	if !strings.Contains(s, "-(x/") || !strings.Contains(s, "-(z/") {
+		t.Fatal(s)
+	}
+}
+
+func TestMatchAllNone(t *testing.T) {
+	eq(t, []int32{0, 1, 2, 3}, query(MatchAll(4)))
+	eq(t, []int32{}, query(MatchAll(0)))
+	eq(t, []int32{}, query(MatchNone()))
+	eq(t, []int32{1, 3}, query(Or(MatchNone(), Term(10, "x", []int32{1, 3}))))
+	eq(t, []int32{}, query(And(MatchNone(), Term(10, "x", []int32{1, 3}))))
+	eq(t, []int32{0, 2, 4}, query(AndNot(Term(10, "x", []int32{1, 3}), MatchAll(5))))
+	eq(t, []int32{0, 1, 2}, query(AndNot(MatchNone(), MatchAll(3))))
+
+	m := MatchAll(10)
+	if m.Advance(4) != 4 || m.Cost() != 6 || m.SetBoost(2).Score() != 2 || m.Advance(20) != NO_MORE {
+		t.Fatal("match all")
+	}
+	if m.String() != "*:10" {
+		t.Fatal(m.String())
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	empty := func() Query { return Term(10, "e", []int32{}) }
+	x := func() Query { return Term(10, "x", []int32{1, 3, 5}) }
+	y := func() Query { return Term(10, "y", []int32{3, 4, 5}) }
+	none := func(q Query) {
+		if r := Rewrite(q); !isMatchNone(r) {
+			t.Fatalf("expected match none, got %s", r.String())
+		}
+	}
+
+	none(empty())
+	none(TermTF(10, 8, "e", []int32{}))
+	none(CreateFileTerm(10, "e", []int32{}))
+	none(And(x(), Or(empty(), empty())))
+	none(And())
+	none(Or())
+	none(DisMax(0.1, empty()))
+	none(WAND(empty(), empty()))
+	none(MaxScore(empty()))
+	none(Constant(1, empty()))
+	none(Bool().Must(x()).Filter(empty()).Should(y()))
+	none(Bool().Should(empty(), x()).SetMinimumShouldMatch(2))
+
+	idx := newPositionalIndex("a b")
+	none(Phrase(idx.term("a"), idx.term("c")))
+	none(Near(1, false, idx.term("c"), idx.term("b")))
+	if isMatchNone(Rewrite(Phrase(idx.terms("a b")...))) {
+		t.Fatal("phrase")
+	}
+
+	// single children are unwrapped, scores stay the same
+	if r := Rewrite(Or(empty(), x(), empty())); r.String() != x().String() {
+		t.Fatal(r.String())
+	}
+	if r := Rewrite(And(Or(x(), empty()))); r.String() != x().String() {
+		t.Fatal(r.String())
+	}
+	eqF(t, queryScores(Or(x(), y())), queryScores(Rewrite(Or(x(), empty(), y(), And(empty(), x())))))
+	eqF(t, queryScores(DisMax(0.5, x(), y())), queryScores(Rewrite(DisMax(0.5, x(), empty(), y()))))
+
+	eq(t, []int32{1}, query(Rewrite(AndNot(empty(), x()).AddNot(y()))))
+	eq(t, []int32{1, 3, 5}, query(Rewrite(And(x()).AddNot(Or(empty())))))
+
+	// minimum should match is resolved before dropping the empty should clauses
+	b := Rewrite(Bool().Should(x(), y(), empty(), empty()).SetMinimumShouldMatchPercent(50))
+	eq(t, []int32{3, 5}, query(b))
+	eq(t, []int32{1, 3, 4, 5}, query(Rewrite(Bool().Should(x(), y(), empty()))))
+	eq(t, []int32{1, 3, 5}, query(Rewrite(Bool().Must(x()).Should(empty()).MustNot(empty()))))
+}
+
+func TestDocRange(t *testing.T) {
+	eq(t, []int32{3, 4, 5}, query(DocRange(3, 5)))
+	eq(t, []int32{0, 1}, query(DocRange(-5, 1)))
+	eq(t, []int32{7}, query(DocRange(7, 7)))
+	eq(t, []int32{}, query(DocRange(7, 6)))
+	eq(t, []int32{5, 9}, query(And(DocRange(4, 9), Term(100, "x", []int32{1, 3, 5, 9, 11}))))
+	eq(t, []int32{3, 11}, query(AndNot(DocRange(4, 9), Term(100, "x", []int32{1, 3, 5, 9, 11}), DocRange(2, 20))))
+
+	// the wide range is not the leading query
+	a := And(DocRange(0, 1000000), Term(100, "x", []int32{1, 3}))
+	if _, ok := a.leading.(*TermQuery); !ok {
+		t.Fatal("leading")
+	}
+
+	r := DocRange(10, 20)
+	if r.Cost() != 11 || r.Advance(15) != 15 || r.Cost() != 6 || r.Next() != 16 || r.Advance(21) != NO_MORE || r.Cost() != 0 {
+		t.Fatal("cost")
+	}
+	r = DocRange(10, 20)
+	if r.Advance(15) != 15 || r.Advance(12) != 15 {
+		t.Fatal("advance backwards")
+	}
+	if !isMatchNone(Rewrite(DocRange(3, 2))) {
+		t.Fatal("rewrite")
+	}
+	eqF(t, []float32{2, 2}, queryScores(DocRange(1, 2).SetBoost(2)))
+}
+
+func TestErr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "err")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := path.Join(dir, "x")
+	if err := AppendFileNameTerm(fn, []int32{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	broken := func() Query {
+		q, err := OpenFileTerm(10, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// reads fail after the file is closed behind its back
+		q.postings.Close()
+		return q
+	}
+
+	for _, q := range []Query{
+		broken(),
+		And(broken(), Term(10, "y", []int32{1, 2})),
+		Or(broken(), Term(10, "y", []int32{1, 2})),
+		DisMax(0.1, broken(), Term(10, "y", []int32{1, 2})),
+		AndNot(broken(), Term(10, "y", []int32{1, 2})),
+		Constant(1, broken()),
+		WAND(broken(), Term(10, "y", []int32{1, 2})),
+		MaxScore(broken(), Term(10, "y", []int32{1, 2})),
+		Bool().Must(Term(10, "y", []int32{1, 2})).Should(broken()),
+	} {
+		for q.Next() != NO_MORE {
+		}
+		if q.Err() == nil {
+			t.Fatalf("%s: expected error", q.String())
+		}
+	}
+
+	eq(t, []int32{1, 2}, query(Or(broken(), Term(10, "y", []int32{1, 2}))))
+	ok := And(CreateFileTerm(10, "x", []int32{1, 2}), Term(10, "y", []int32{2}))
+	eq(t, []int32{2}, query(ok))
+	if ok.Err() != nil {
+		t.Fatal(ok.Err())
+	}
+
+	// advancing to the current document keeps the file open
+	same := CreateFileTerm(10, "x", []int32{1, 2, 3})
+	if same.Advance(2) != 2 || same.Advance(2) != 2 || same.Next() != 3 || same.Err() != nil {
+		t.Fatal("advance to the current document")
+	}
+	same.Close()
+
+	if _, err := OpenFileTerm(10, path.Join(fn, "not_a_directory")); err == nil {
+		t.Fatal("expected open error")
+	}
+	if _, err := OpenMmapFileTerm(10, path.Join(fn, "not_a_directory")); err == nil {
+		t.Fatal("expected open error")
+	}
+	if q, err := OpenFileTerm(10, path.Join(dir, "missing")); err != nil || q.Next() != NO_MORE || q.String() != "<missing>" {
+		t.Fatal("missing")
+	}
+}
+
+func TestClose(t *testing.T) {
+	files := []*FileTermData{}
+	file := func(postings ...int32) Query {
+		q := CreateFileTerm(10, "x", postings).(*FileTermData)
+		files = append(files, q)
+		return q
+	}
+	closed := func() int {
+		n := 0
+		for _, f := range files {
+			if f.closed {
+				n++
+			}
+		}
+		return n
+	}
+
+	q := And(
+		file(1, 2, 3),
+		Or(file(5, 6), DisMax(0.1, file(7), Constant(1, file(8)))),
+		WAND(file(1, 9)),
+		MaxScore(file(1, 9)),
+		Bool().Must(file(1, 9)).Should(file(1)).Filter(file(1, 9)).MustNot(file(4)),
+	).AddNot(file(1))
+	eq(t, []int32{}, query(q))
+	if closed() == len(files) {
+		t.Fatal("expected some files to be open")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closed() != len(files) {
+		t.Fatalf("%d of %d closed", closed(), len(files))
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := newPositionalIndex("a b")
+	for _, q := range []Query{Phrase(idx.terms("a b")...), Near(1, true, idx.terms("a b")...), MatchAll(3), MatchNone(), DocRange(1, 2)} {
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// rewrite closes the dropped queries
+	files = nil
+	r := Rewrite(And(file(1, 2), Or(file(1), file()), file()))
+	if !isMatchNone(r) || closed() != len(files) {
+		t.Fatalf("%d of %d closed", closed(), len(files))
+	}
+	for _, q := range []Query{
+		And(file(), file(1)).AddNot(file(2)),
+		Bool().Must(file(1)).Filter(file()).Should(file(1)).MustNot(file(2)),
+		Bool().Should(file(), file()).MustNot(file(2)),
+		Constant(1, Or(file(), file())),
+	} {
+		files = files[:0]
+		if r := Rewrite(q); !isMatchNone(r) || closed() != len(files) {
+			t.Fatalf("%s: %d of %d closed", q.String(), closed(), len(files))
+		}
+	}
+
+	m := CreateMmapFileTerm(10, []int32{1, 2, 3})
+	if m.Next() != 1 || m.closed {
+		t.Fatal("closed before exhausted")
+	}
+	if m.Close() != nil || !m.closed || m.Close() != nil {
+		t.Fatal("mmap close")
+	}
+}
@@ -0,0 +1,191 @@
+package query
+
+func isMatchNone(q Query) bool {
+	_, ok := q.(*MatchNoneQuery)
+	return ok
+}
+
+// Rewrites the query to a simpler one that matches the same documents
+// with the same scores, terms without postings become MatchNone(),
+// which is then collapsed in the parents: AND with a MatchNone()
+// matches nothing, OR and DisMax drop it, excluding it does nothing
+// and so on, e.g.
+//
+//	Rewrite(And(Term(n, "a", []int32{1}), Or(Term(n, "b", []int32{}))))
+//
+// returns MatchNone()
+//
+// The composite queries are modified in place, so call it before
+// iterating and use the returned query, the dropped queries are closed
+func Rewrite(q Query) Query {
+	r := rewrite(q)
+	if r != q && isMatchNone(r) {
+		// releases the files of the whole dropped branch
+		q.Close()
+	}
+	return r
+}
+
+func rewrite(q Query) Query {
+	switch t := q.(type) {
+	case *TermQuery:
+		if len(t.data.postings) == 0 {
+			return MatchNone()
+		}
+	case *TermTFQuery:
+		if len(t.data.postings) == 0 {
+			return MatchNone()
+		}
+	case *PayloadTermQuery:
+		if len(t.term.data.postings) == 0 {
+			return MatchNone()
+		}
+	case *PositionalTermQuery:
+		if len(t.term.data.postings) == 0 {
+			return MatchNone()
+		}
+	case *CompressedTermQuery:
+		if t.n == 0 {
+			return MatchNone()
+		}
+	case *RoaringTermQuery:
+		if t.n == 0 {
+			return MatchNone()
+		}
+	case *FileTermData:
+		if t.n == 0 {
+			return MatchNone()
+		}
+	case *DocRangeQuery:
+		if t.min > t.max {
+			return MatchNone()
+		}
+	case *NumericRangeQuery:
+		if t.count == 0 {
+			return MatchNone()
+		}
+	case *MmapFileTermData:
+		if len(t.term.data.postings) == 0 {
+			return MatchNone()
+		}
+	case *ConstantQuery:
+		t.query = Rewrite(t.query)
+		if isMatchNone(t.query) {
+			return MatchNone()
+		}
+	case *AndQuery:
+		return rewriteAnd(t)
+	case *OrQuery:
+		t.queries = rewriteDisjunction(t.queries)
+		if len(t.queries) == 0 {
+			return MatchNone()
+		}
+		if len(t.queries) == 1 && t.boost == 1 {
+			return t.queries[0]
+		}
+	case *DisMaxQuery:
+		t.queries = rewriteDisjunction(t.queries)
+		t.scores = make([]float32, len(t.queries))
+		if len(t.queries) == 0 {
+			return MatchNone()
+		}
+		if len(t.queries) == 1 && t.boost == 1 {
+			return t.queries[0]
+		}
+	case *WANDQuery:
+		t.queries = rewriteDisjunction(t.queries)
+		t.upperBounds = nil
+		if len(t.queries) == 0 {
+			return MatchNone()
+		}
+	case *MaxScoreQuery:
+		t.queries = rewriteDisjunction(t.queries)
+		t.sorted = nil
+		if len(t.queries) == 0 {
+			return MatchNone()
+		}
+	case *BoolQuery:
+		return rewriteBool(t)
+	case *PhraseQuery:
+		if len(t.terms) == 0 || anyMatchNone(t.terms) {
+			return MatchNone()
+		}
+	case *NearQuery:
+		if len(t.terms) == 0 || anyMatchNone(t.terms) {
+			return MatchNone()
+		}
+	}
+	return q
+}
+
+func anyMatchNone(terms []PositionalQuery) bool {
+	for _, t := range terms {
+		if isMatchNone(Rewrite(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rewriteDisjunction(queries []Query) []Query {
+	out := []Query{}
+	for _, s := range queries {
+		s = Rewrite(s)
+		if !isMatchNone(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func rewriteAnd(q *AndQuery) Query {
+	if len(q.queries) == 0 {
+		return MatchNone()
+	}
+
+	for i, s := range q.queries {
+		q.queries[i] = Rewrite(s)
+		if isMatchNone(q.queries[i]) {
+			return MatchNone()
+		}
+	}
+	q.sortSubqueries()
+	q.nots = rewriteDisjunction(q.nots)
+
+	if len(q.queries) == 1 && len(q.nots) == 0 && q.boost == 1 {
+		return q.queries[0]
+	}
+	return q
+}
+
+func rewriteBool(q *BoolQuery) Query {
+	// the clauses are replaced, so the required And is built again
+	q.unprepare()
+	for i, s := range q.must {
+		q.must[i] = Rewrite(s)
+		if isMatchNone(q.must[i]) {
+			return MatchNone()
+		}
+	}
+	for i, s := range q.filter {
+		q.filter[i] = Rewrite(s)
+		if isMatchNone(q.filter[i]) {
+			return MatchNone()
+		}
+	}
+
+	// the minimum depends on the number of should clauses, so it has to
+	// be resolved before dropping the ones that can not match
+	minimum := q.resolveMinimumShouldMatch()
+	should := rewriteDisjunction(q.should)
+	if len(should) != len(q.should) {
+		q.should = should
+		q.SetMinimumShouldMatch(minimum)
+	}
+	q.mustNot = rewriteDisjunction(q.mustNot)
+
+	if minimum > len(q.should) || len(q.must)+len(q.filter)+len(q.should) == 0 {
+		return MatchNone()
+	}
+	return q
+}
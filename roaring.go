@@ -0,0 +1,296 @@
+package query
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// arrays bigger than this are stored as bitmaps
+	arrayContainerMax = 4096
+	bitmapWords       = 1 << 16 / 64
+)
+
+// Container of the low 16 bits of the documents that have the same
+// high 16 bits
+type container interface {
+	cardinality() int
+	contains(low uint16) bool
+	// returns the smallest value >= low, -1 if none
+	next(low int) int
+	// the documents in increasing order
+	values() []uint16
+	sizeInBytes() int
+}
+
+type arrayContainer []uint16
+
+func (a arrayContainer) cardinality() int {
+	return len(a)
+}
+
+func (a arrayContainer) contains(low uint16) bool {
+	i := sort.Search(len(a), func(i int) bool { return a[i] >= low })
+	return i < len(a) && a[i] == low
+}
+
+func (a arrayContainer) next(low int) int {
+	i := sort.Search(len(a), func(i int) bool { return int(a[i]) >= low })
+	if i == len(a) {
+		return -1
+	}
+	return int(a[i])
+}
+
+func (a arrayContainer) values() []uint16 {
+	return a
+}
+
+func (a arrayContainer) sizeInBytes() int {
+	return 2 * len(a)
+}
+
+type bitmapContainer struct {
+	words []uint64
+	n     int
+}
+
+func newBitmapContainer(values []uint16) *bitmapContainer {
+	b := &bitmapContainer{words: make([]uint64, bitmapWords), n: len(values)}
+	for _, v := range values {
+		b.words[v>>6] |= 1 << (v & 63)
+	}
+	return b
+}
+
+func (b *bitmapContainer) cardinality() int {
+	return b.n
+}
+
+func (b *bitmapContainer) contains(low uint16) bool {
+	return b.words[low>>6]&(1<<(low&63)) != 0
+}
+
+func (b *bitmapContainer) next(low int) int {
+	if low >= 1<<16 {
+		return -1
+	}
+	i := low >> 6
+	w := b.words[i] >> uint(low&63)
+	if w != 0 {
+		return low + bits.TrailingZeros64(w)
+	}
+	for i++; i < len(b.words); i++ {
+		if b.words[i] != 0 {
+			return i*64 + bits.TrailingZeros64(b.words[i])
+		}
+	}
+	return -1
+}
+
+func (b *bitmapContainer) values() []uint16 {
+	out := make([]uint16, 0, b.n)
+	for i, w := range b.words {
+		for w != 0 {
+			out = append(out, uint16(i*64+bits.TrailingZeros64(w)))
+			w &= w - 1
+		}
+	}
+	return out
+}
+
+func (b *bitmapContainer) sizeInBytes() int {
+	return 8 * bitmapWords
+}
+
+type run struct {
+	start uint16
+	last  uint16
+}
+
+type runContainer struct {
+	runs []run
+	n    int
+}
+
+func (r *runContainer) cardinality() int {
+	return r.n
+}
+
+// returns the index of the first run that ends at or after low
+func (r *runContainer) search(low int) int {
+	return sort.Search(len(r.runs), func(i int) bool { return int(r.runs[i].last) >= low })
+}
+
+func (r *runContainer) contains(low uint16) bool {
+	i := r.search(int(low))
+	return i < len(r.runs) && r.runs[i].start <= low
+}
+
+func (r *runContainer) next(low int) int {
+	i := r.search(low)
+	if i == len(r.runs) {
+		return -1
+	}
+	if int(r.runs[i].start) > low {
+		return int(r.runs[i].start)
+	}
+	return low
+}
+
+func (r *runContainer) values() []uint16 {
+	out := make([]uint16, 0, r.n)
+	for _, run := range r.runs {
+		for v := int(run.start); v <= int(run.last); v++ {
+			out = append(out, uint16(v))
+		}
+	}
+	return out
+}
+
+func (r *runContainer) sizeInBytes() int {
+	return 4 * len(r.runs)
+}
+
+// picks the smallest container for the sorted unique values
+func newContainer(values []uint16) container {
+	runs := []run{}
+	for i, v := range values {
+		if i > 0 && values[i-1]+1 == v {
+			runs[len(runs)-1].last = v
+		} else {
+			runs = append(runs, run{start: v, last: v})
+		}
+	}
+
+	array := 2 * len(values)
+	bitmap := 8 * bitmapWords
+	if 4*len(runs) < array && 4*len(runs) < bitmap {
+		return &runContainer{runs: runs, n: len(values)}
+	}
+	if len(values) <= arrayContainerMax {
+		c := make(arrayContainer, len(values))
+		copy(c, values)
+		return c
+	}
+	return newBitmapContainer(values)
+}
+
+func andContainers(a, b container) container {
+	if a.cardinality() > b.cardinality() {
+		a, b = b, a
+	}
+
+	ba, okA := a.(*bitmapContainer)
+	bb, okB := b.(*bitmapContainer)
+	if okA && okB {
+		words := make([]uint64, bitmapWords)
+		n := 0
+		for i := range words {
+			words[i] = ba.words[i] & bb.words[i]
+			n += bits.OnesCount64(words[i])
+		}
+		if n > arrayContainerMax {
+			return &bitmapContainer{words: words, n: n}
+		}
+		return newContainer((&bitmapContainer{words: words, n: n}).values())
+	}
+
+	// the smaller one is probed against the bigger one
+	out := []uint16{}
+	for _, v := range a.values() {
+		if b.contains(v) {
+			out = append(out, v)
+		}
+	}
+	return newContainer(out)
+}
+
+// Compressed set of document ids, split by the high 16 bits into
+// containers of the low 16 bits, each container is a sorted array, a
+// bitmap or a list of runs, whichever is smaller, similar to roaring
+// bitmaps (https://roaringbitmap.org)
+type Bitmap struct {
+	keys       []uint16
+	containers []container
+}
+
+// Creates bitmap from sorted postings list
+func NewBitmap(postings []int32) *Bitmap {
+	b := &Bitmap{}
+	low := make([]uint16, 0, 1024)
+	for i := 0; i < len(postings); {
+		key := uint16(postings[i] >> 16)
+		low = low[:0]
+		for ; i < len(postings) && uint16(postings[i]>>16) == key; i++ {
+			v := uint16(postings[i])
+			if len(low) == 0 || low[len(low)-1] != v {
+				low = append(low, v)
+			}
+		}
+		b.keys = append(b.keys, key)
+		b.containers = append(b.containers, newContainer(low))
+	}
+	return b
+}
+
+// Returns the number of documents
+func (b *Bitmap) Cardinality() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.cardinality()
+	}
+	return n
+}
+
+func (b *Bitmap) Contains(docId int32) bool {
+	if docId < 0 {
+		return false
+	}
+	key := uint16(docId >> 16)
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+	return i < len(b.keys) && b.keys[i] == key && b.containers[i].contains(uint16(docId))
+}
+
+// Returns the documents as sorted postings list
+func (b *Bitmap) ToArray() []int32 {
+	out := make([]int32, 0, b.Cardinality())
+	for i, c := range b.containers {
+		high := int32(b.keys[i]) << 16
+		for _, v := range c.values() {
+			out = append(out, high|int32(v))
+		}
+	}
+	return out
+}
+
+// Returns the size of the containers in bytes
+func (b *Bitmap) SizeInBytes() int {
+	size := 2 * len(b.keys)
+	for _, c := range b.containers {
+		size += c.sizeInBytes()
+	}
+	return size
+}
+
+// Returns new bitmap with the documents that are in both
+func (b *Bitmap) And(other *Bitmap) *Bitmap {
+	out := &Bitmap{}
+	i, j := 0, 0
+	for i < len(b.keys) && j < len(other.keys) {
+		if b.keys[i] < other.keys[j] {
+			i++
+		} else if b.keys[i] > other.keys[j] {
+			j++
+		} else {
+			c := andContainers(b.containers[i], other.containers[j])
+			if c.cardinality() > 0 {
+				out.keys = append(out.keys, b.keys[i])
+				out.containers = append(out.containers, c)
+			}
+			i++
+			j++
+		}
+	}
+	return out
+}
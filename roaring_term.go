@@ -0,0 +1,182 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+)
+
+type RoaringTermQuery struct {
+	docId  int32
+	bitmap *Bitmap
+	n      int
+	// cost[i] is the number of documents in containers[i:]
+	cost       []int
+	current    int
+	term       string
+	idf        float32
+	boost      float32
+	totalDocs  int
+	similarity Similarity
+	// the bitmap is partial, see FilterCache.Filter()
+	err error
+}
+
+// Same as Term() but the postings are in a Bitmap, better for very
+// dense terms (e.g. country or category), AND of two or more
+// RoaringTerm queries intersects the bitmaps directly instead of
+// advancing the iterators one by one
+//
+// use Reset() to iterate it again, Clone() for another goroutine (see
+// Query), the bitmap can be shared
+func RoaringTerm(totalDocumentsInIndex int, t string, b *Bitmap) *RoaringTermQuery {
+	q := &RoaringTermQuery{
+		docId:      NOT_READY,
+		bitmap:     b,
+		cost:       make([]int, len(b.containers)+1),
+		term:       t,
+		boost:      1,
+		totalDocs:  totalDocumentsInIndex,
+		similarity: TFIDF{},
+	}
+	for i := len(b.containers) - 1; i >= 0; i-- {
+		q.cost[i] = q.cost[i+1] + b.containers[i].cardinality()
+	}
+	q.n = q.cost[0]
+	if q.n > 0 {
+		q.idf = computeIDF(totalDocumentsInIndex, q.n)
+	}
+	return q
+}
+
+func (t *RoaringTermQuery) GetDocId() int32 {
+	return t.docId
+}
+
+// Number of documents in the remaining containers
+func (t *RoaringTermQuery) Cost() int {
+	if t.docId == NO_MORE {
+		return 0
+	}
+	return t.cost[t.current]
+}
+
+func (t *RoaringTermQuery) String() string {
+	return fmt.Sprintf("%s/%.2f", t.term, t.idf)
+}
+
+func (t *RoaringTermQuery) Score() float32 {
+	return t.similarity.Score(t.idf, 1, t.docId) * t.boost
+}
+
+// Score with the given similarity instead of TFIDF, the term
+// frequency is always 1
+func (t *RoaringTermQuery) SetSimilarity(s Similarity) *RoaringTermQuery {
+	t.similarity = s
+	if t.n > 0 {
+		t.idf = s.IDF(t.totalDocs, t.n)
+	}
+	return t
+}
+
+func (t *RoaringTermQuery) UpperBound() float32 {
+	if t.n == 0 {
+		return 0
+	}
+	return boostUpperBound(t.similarity.MaxScore(t.idf, 1), t.boost)
+}
+
+// Each container is a block, the term frequency is always 1, so all
+// blocks have the same upper bound
+func (t *RoaringTermQuery) BlockUpperBound(target int32) (int32, float32) {
+	found := t.searchContainer(target)
+	if found == len(t.bitmap.keys) {
+		return NO_MORE, 0
+	}
+	return int32(t.bitmap.keys[found])<<16 | 0xffff, t.UpperBound()
+}
+
+func (t *RoaringTermQuery) Explain() *Explanation {
+	if t.docId == NOT_READY || t.docId == NO_MORE {
+		return explainNotReady(t.docId, "term "+t.term)
+	}
+	return explainTerm(t.Score(), t.term, t.similarity, t.idf, t.totalDocs, t.n, 1, t.boost)
+}
+
+// Returns the index of the first container that can contain target,
+// starting from the current one
+func (t *RoaringTermQuery) searchContainer(target int32) int {
+	keys := t.bitmap.keys[t.current:]
+	key := uint16(target >> 16)
+	return t.current + sort.Search(len(keys), func(i int) bool {
+		return keys[i] >= key
+	})
+}
+
+func (t *RoaringTermQuery) Advance(target int32) int32 {
+	if t.docId == NO_MORE || target == NO_MORE {
+		t.docId = NO_MORE
+		return NO_MORE
+	}
+	if target <= t.docId {
+		return t.docId
+	}
+	if target < 0 {
+		target = 0
+	}
+
+	t.current = t.searchContainer(target)
+	low := int(target & 0xffff)
+	for ; t.current < len(t.bitmap.keys); t.current++ {
+		key := int32(t.bitmap.keys[t.current])
+		if key > target>>16 {
+			low = 0
+		}
+		if next := t.bitmap.containers[t.current].next(low); next >= 0 {
+			t.docId = key<<16 | int32(next)
+			return t.docId
+		}
+	}
+	t.docId = NO_MORE
+	return NO_MORE
+}
+
+func (t *RoaringTermQuery) Next() int32 {
+	if t.docId == NOT_READY {
+		return t.Advance(0)
+	}
+	return t.Advance(t.docId + 1)
+}
+
+func (t *RoaringTermQuery) SetBoost(b float32) Query {
+	t.boost = b
+	return t
+}
+
+func (t *RoaringTermQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (t *RoaringTermQuery) AddSubQuery(Query) Query {
+	panic("unsupported")
+}
+
+func (t *RoaringTermQuery) Err() error {
+	return t.err
+}
+
+// nothing to release
+func (t *RoaringTermQuery) Close() error {
+	return nil
+}
+
+func (t *RoaringTermQuery) Reset() {
+	t.docId = NOT_READY
+	t.current = 0
+}
+
+// The bitmap is shared
+func (t *RoaringTermQuery) Clone() Query {
+	c := *t
+	c.Reset()
+	return &c
+}
@@ -0,0 +1,206 @@
+package query
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+// mix of sparse, dense and consecutive documents, so all container
+// types are used
+func randomBitmapPostings(n int) []int32 {
+	out := []int32{}
+	doc := int32(rand.Intn(1000))
+	for len(out) < n {
+		switch rand.Intn(3) {
+		case 0:
+			doc += 1 + int32(rand.Intn(50000))
+		case 1:
+			doc += 1 + int32(rand.Intn(3))
+		default:
+			doc++
+		}
+		out = append(out, doc)
+	}
+	return out
+}
+
+func TestBitmap(t *testing.T) {
+	rand.Seed(0)
+	for _, n := range []int{0, 1, 100, 5000, 100000} {
+		postings := randomBitmapPostings(n)
+		b := NewBitmap(postings)
+		eq(t, postings, b.ToArray())
+		if b.Cardinality() != n {
+			t.Fatal("cardinality")
+		}
+		for _, p := range postings {
+			if !b.Contains(p) {
+				t.Fatalf("%d missing", p)
+			}
+		}
+		if b.Contains(-1) || (n > 0 && b.Contains(postings[n-1]+1)) {
+			t.Fatal("contains")
+		}
+
+		other := randomBitmapPostings(n)
+		expected := query(And(Term(10, "a", postings), Term(10, "b", other)))
+		eq(t, expected, b.And(NewBitmap(other)).ToArray())
+	}
+
+	types := map[string]bool{}
+	mixed := randomBitmapPostings(300000)
+	for i := int32(0); i < 20000; i++ {
+		mixed = append(mixed, mixed[len(mixed)-1]+2)
+	}
+	for _, c := range NewBitmap(mixed).containers {
+		types[fmt.Sprintf("%T", c)] = true
+	}
+	if len(types) != 3 {
+		t.Fatal(types)
+	}
+
+	dense := []int32{}
+	for i := int32(0); i < 1000000; i++ {
+		dense = append(dense, i)
+	}
+	if size := NewBitmap(dense).SizeInBytes(); size > 100 {
+		t.Fatalf("%d bytes", size)
+	}
+}
+
+func TestRoaringTerm(t *testing.T) {
+	rand.Seed(0)
+	for _, n := range []int{0, 1, 5000, 100000} {
+		postings := randomBitmapPostings(n)
+		eq(t, postings, query(RoaringTerm(10, "x", NewBitmap(postings))))
+		eqF(t, queryScores(Term(1000000, "x", postings)), queryScores(RoaringTerm(1000000, "x", NewBitmap(postings))))
+
+		for k := 0; k < 20; k++ {
+			a, b := Term(10, "x", postings), RoaringTerm(10, "x", NewBitmap(postings))
+			for a.GetDocId() != NO_MORE {
+				var da, db int32
+				if rand.Intn(2) == 0 {
+					da, db = a.Next(), b.Next()
+				} else {
+					target := a.GetDocId() + int32(rand.Intn(200000)) - 10
+					da, db = a.Advance(target), b.Advance(target)
+				}
+				if da != db {
+					t.Fatalf("%d != %d", da, db)
+				}
+			}
+		}
+	}
+
+	// the bitmaps are intersected, the scores are the same
+	a, b, c := randomBitmapPostings(50000), randomBitmapPostings(50000), randomBitmapPostings(50000)
+	roaring := func(p []int32) Query {
+		return RoaringTerm(1000000, "x", NewBitmap(p))
+	}
+	and := And(roaring(a), Term(1000000, "c", c), roaring(b))
+	if and.intersection == nil {
+		t.Fatal("no intersection")
+	}
+	eq(t, query(And(Term(1000000, "a", a), Term(1000000, "b", b), Term(1000000, "c", c))), query(and))
+	eqF(t,
+		queryScores(And(Term(1000000, "a", a), Term(1000000, "b", b))),
+		queryScores(And(roaring(a), roaring(b))),
+	)
+	eq(t, query(AndNot(Term(1, "c", c), Term(1, "a", a), Term(1, "b", b))), query(AndNot(Term(1, "c", c), roaring(a), roaring(b))))
+
+	hits, _ := TopK(WAND(roaring(a), Term(1000000, "b", b[:100])), 10)
+	expected, _ := TopK(Or(Term(1000000, "a", a), Term(1000000, "b", b[:100])), 10)
+	eqHits(t, expected, hits)
+	if !isMatchNone(Rewrite(roaring([]int32{}))) {
+		t.Fatal("rewrite")
+	}
+}
+
+func TestFilterCache(t *testing.T) {
+	c := NewFilterCache(2)
+	created := 0
+	filter := func(key string, postings ...int32) Query {
+		return c.Filter(key, func() Query {
+			created++
+			return Term(10, key, postings)
+		})
+	}
+
+	eq(t, []int32{1, 3}, query(filter("a", 1, 3)))
+	eq(t, []int32{1, 3}, query(filter("a")))
+	eq(t, []int32{2, 3}, query(filter("b", 2, 3)))
+	eq(t, []int32{3}, query(And(filter("a"), filter("b"))))
+	eq(t, []int32{4}, query(filter("c", 4)))
+	// a was least recently used
+	eq(t, []int32{}, query(filter("a")))
+	if created != 4 || c.Len() != 2 {
+		t.Fatalf("created: %d, len: %d", created, c.Len())
+	}
+	hits, misses := c.Stats()
+	if hits != 3 || misses != 4 {
+		t.Fatalf("hits: %d, misses: %d", hits, misses)
+	}
+	eqF(t, []float32{1}, queryScores(filter("c")))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("k%d", i%3)
+			q := c.Filter(key, func() Query {
+				return Term(10, key, []int32{int32(i % 3)})
+			})
+			if q.Next() != int32(i%3) {
+				t.Error("concurrent")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFilterCacheErr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := path.Join(dir, "x")
+	if err := AppendFileNameTerm(fn, []int32{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewFilterCache(2)
+	created := 0
+	broken := func() Query {
+		created++
+		q, err := OpenFileTerm(10, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// reads fail after the file is closed behind its back
+		q.postings.Close()
+		return q
+	}
+
+	for i := 0; i < 2; i++ {
+		q := c.Filter("x", broken)
+		if q.Err() == nil || q.Clone().Err() == nil {
+			t.Fatal("expected error")
+		}
+		and := And(q, c.Filter("y", func() Query { return Term(10, "y", []int32{1, 2}) }))
+		query(and)
+		if and.Err() == nil {
+			t.Fatal("expected error from and")
+		}
+	}
+	// the partial result is not cached
+	if created != 2 || c.Len() != 1 {
+		t.Fatalf("created: %d, len: %d", created, c.Len())
+	}
+}
@@ -0,0 +1,512 @@
+package query
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	segmentMagic      = 0x47515347 // GQSG
+	segmentVersion    = 2
+	segmentHeaderSize = 8
+	segmentFooterSize = 20
+)
+
+var (
+	ErrCorruptSegment = errors.New("corrupt segment")
+	ErrSegmentClosed  = errors.New("segment is closed")
+)
+
+type segmentTerm struct {
+	postings []int32
+	freqs    []int32
+	hasFreqs bool
+	payload  []byte
+}
+
+// Builds a segment: a single file with the postings, the term
+// frequencies and the payloads of all terms, followed by the sorted
+// term dictionary and a footer with crc32 checksum of the whole file
+//
+//	header:     magic, version
+//	terms:      postings (see EncodePostings), frequencies and payload of each term
+//	dictionary: term, docFreq and the offsets of its data
+//	footer:     dictionary offset, total documents, magic, crc32
+//
+// All integers are encoded in ByteOrder (little endian by default)
+type IndexWriter struct {
+	terms     map[string]*segmentTerm
+	totalDocs int
+}
+
+func NewIndexWriter() *IndexWriter {
+	return &IndexWriter{
+		terms: map[string]*segmentTerm{},
+	}
+}
+
+func (w *IndexWriter) term(t string) *segmentTerm {
+	st, ok := w.terms[t]
+	if !ok {
+		st = &segmentTerm{}
+		w.terms[t] = st
+	}
+	return st
+}
+
+// Adds document with term frequency tf to the term, the documents of
+// each term have to be added in increasing order, adding the same
+// document again only updates the frequency. Terms with payload can
+// not have frequencies
+func (w *IndexWriter) Add(t string, docId int32, tf int32) error {
+	if docId < 0 {
+		return fmt.Errorf("negative document id %d", docId)
+	}
+	if tf < 1 {
+		return fmt.Errorf("term %s: document %d with term frequency %d", t, docId, tf)
+	}
+	st := w.term(t)
+	if tf != 1 && len(st.payload) > 0 {
+		return fmt.Errorf("term %s: has payload, it can not have frequencies", t)
+	}
+	n := len(st.postings)
+	if n > 0 && st.postings[n-1] > docId {
+		return fmt.Errorf("term %s: document %d added after %d", t, docId, st.postings[n-1])
+	}
+	if tf != 1 {
+		st.hasFreqs = true
+	}
+	if n > 0 && st.postings[n-1] == docId {
+		st.freqs[n-1] = tf
+	} else {
+		st.postings = append(st.postings, docId)
+		st.freqs = append(st.freqs, tf)
+	}
+	if int(docId) >= w.totalDocs {
+		w.totalDocs = int(docId) + 1
+	}
+	return nil
+}
+
+// Sets the payload of the term, see PayloadTerm(), PayloadTerm does
+// not score with the term frequency, so terms with frequencies can not
+// have payload
+func (w *IndexWriter) SetPayload(t string, payload []byte) error {
+	st := w.term(t)
+	if st.hasFreqs && len(payload) > 0 {
+		return fmt.Errorf("term %s: has frequencies, it can not have payload", t)
+	}
+	st.payload = payload
+	return nil
+}
+
+// Sets the number of documents in the index, used for the idf, by
+// default it is the biggest added document id + 1
+func (w *IndexWriter) SetTotalDocs(n int) *IndexWriter {
+	w.totalDocs = n
+	return w
+}
+
+type segmentEncoder struct {
+	w      *bufio.Writer
+	offset uint64
+	err    error
+}
+
+func (e *segmentEncoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+	e.offset += uint64(len(b))
+}
+
+func (e *segmentEncoder) uint32(v uint32) {
+	b := make([]byte, 4)
+	ByteOrder.PutUint32(b, v)
+	e.write(b)
+}
+
+func (e *segmentEncoder) uint64(v uint64) {
+	b := make([]byte, 8)
+	ByteOrder.PutUint64(b, v)
+	e.write(b)
+}
+
+func (e *segmentEncoder) uvarint(v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	e.write(b[:binary.PutUvarint(b, v)])
+}
+
+// Writes the segment
+func (w *IndexWriter) WriteTo(out io.Writer) (int64, error) {
+	terms := make([]string, 0, len(w.terms))
+	for t := range w.terms {
+		terms = append(terms, t)
+	}
+	sort.Strings(terms)
+
+	crc := crc32.NewIEEE()
+	e := &segmentEncoder{w: bufio.NewWriter(io.MultiWriter(out, crc))}
+	e.uint32(segmentMagic)
+	e.uint32(segmentVersion)
+
+	entries := make([]segmentEntry, len(terms))
+	for i, t := range terms {
+		st := w.terms[t]
+		entry := segmentEntry{term: t, docFreq: len(st.postings)}
+
+		postings, err := EncodePostings(st.postings)
+		if err != nil {
+			return int64(e.offset), fmt.Errorf("term %s: %s", t, err.Error())
+		}
+		entry.postingsOffset = e.offset
+		e.write(postings)
+		entry.postingsLen = e.offset - entry.postingsOffset
+
+		entry.freqsOffset = e.offset
+		if st.hasFreqs {
+			e.write(encodeInts(st.freqs))
+		}
+		entry.freqsLen = e.offset - entry.freqsOffset
+
+		entry.payloadOffset = e.offset
+		e.write(st.payload)
+		entry.payloadLen = e.offset - entry.payloadOffset
+
+		entries[i] = entry
+	}
+
+	dictOffset := e.offset
+	e.uvarint(uint64(len(entries)))
+	for _, entry := range entries {
+		e.uvarint(uint64(len(entry.term)))
+		e.write([]byte(entry.term))
+		e.uvarint(uint64(entry.docFreq))
+		e.uvarint(entry.postingsOffset)
+		e.uvarint(entry.postingsLen)
+		e.uvarint(entry.freqsOffset)
+		e.uvarint(entry.freqsLen)
+		e.uvarint(entry.payloadOffset)
+		e.uvarint(entry.payloadLen)
+	}
+
+	e.uint64(dictOffset)
+	e.uint32(uint32(w.totalDocs))
+	e.uint32(segmentMagic)
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	if e.err != nil {
+		return int64(e.offset), e.err
+	}
+
+	// the checksum covers everything before it
+	b := make([]byte, 4)
+	ByteOrder.PutUint32(b, crc.Sum32())
+	n, err := out.Write(b)
+	return int64(e.offset) + int64(n), err
+}
+
+// Writes the segment to temporary file in the same directory and
+// renames it to fn, so readers never see partially written segment
+func (w *IndexWriter) WriteFile(fn string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fn), filepath.Base(fn)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteTo(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fn)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+type segmentEntry struct {
+	term           string
+	docFreq        int
+	postingsOffset uint64
+	postingsLen    uint64
+	freqsOffset    uint64
+	freqsLen       uint64
+	payloadOffset  uint64
+	payloadLen     uint64
+}
+
+// Reads segment written by IndexWriter, the checksum is verified when
+// it is opened and the postings are decoded while the term queries
+// iterate them. It is never modified after it is opened, so one reader
+// can be used from many goroutines, each creating its own queries
+type SegmentReader struct {
+	// nil after Close()
+	data      []byte
+	entries   []segmentEntry
+	totalDocs int
+	// nil if the segment is not mapped, the queries keep a reference
+	mapping *mapping
+}
+
+// Maps the segment file in memory (reads it on systems without mmap)
+// and verifies its checksum, Close() the reader when done with it and
+// with all the queries created from it
+func OpenSegment(fn string) (*SegmentReader, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() < segmentHeaderSize+segmentFooterSize {
+		return nil, ErrCorruptSegment
+	}
+	data, err := mmapFile(f, int(stat.Size()))
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewSegmentReader(data)
+	if err != nil {
+		munmapFile(data)
+		return nil, err
+	}
+	r.mapping = &mapping{data: data, refs: 1}
+	return r, nil
+}
+
+// Releases the segment, the accessors return ErrSegmentClosed after
+// that. The file opened with OpenSegment is unmapped when the reader
+// and all the queries created from it are closed
+func (r *SegmentReader) Close() error {
+	if r.data == nil {
+		return nil
+	}
+	r.data = nil
+	if r.mapping != nil {
+		return r.mapping.release()
+	}
+	return nil
+}
+
+// Creates reader from the bytes of a segment, they must not be modified
+func NewSegmentReader(data []byte) (*SegmentReader, error) {
+	if len(data) < segmentHeaderSize+segmentFooterSize {
+		return nil, ErrCorruptSegment
+	}
+	footer := data[len(data)-segmentFooterSize:]
+	if ByteOrder.Uint32(data) != segmentMagic || ByteOrder.Uint32(footer[12:]) != segmentMagic {
+		return nil, ErrCorruptSegment
+	}
+	if ByteOrder.Uint32(data[4:]) != segmentVersion {
+		return nil, fmt.Errorf("unsupported segment version %d", ByteOrder.Uint32(data[4:]))
+	}
+	if crc32.ChecksumIEEE(data[:len(data)-4]) != ByteOrder.Uint32(footer[16:]) {
+		return nil, ErrCorruptSegment
+	}
+
+	r := &SegmentReader{
+		data:      data,
+		totalDocs: int(ByteOrder.Uint32(footer[8:])),
+	}
+	dictOffset := ByteOrder.Uint64(footer)
+	dataEnd := uint64(len(data) - segmentFooterSize)
+	if dictOffset < segmentHeaderSize || dictOffset > dataEnd {
+		return nil, ErrCorruptSegment
+	}
+	if err := r.readDictionary(data[dictOffset:dataEnd], dictOffset); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SegmentReader) readDictionary(dict []byte, end uint64) error {
+	pos := 0
+	uvarint := func() uint64 {
+		if pos < 0 {
+			return 0
+		}
+		v, n := binary.Uvarint(dict[pos:])
+		if n <= 0 {
+			pos = -1
+			return 0
+		}
+		pos += n
+		return v
+	}
+	section := func(offset, length uint64) bool {
+		return offset >= segmentHeaderSize && offset+length >= offset && offset+length <= end
+	}
+
+	count := uvarint()
+	if pos < 0 || count > uint64(len(dict)) {
+		return ErrCorruptSegment
+	}
+	r.entries = make([]segmentEntry, count)
+	for i := range r.entries {
+		termLen := uvarint()
+		if pos < 0 || uint64(pos)+termLen > uint64(len(dict)) {
+			return ErrCorruptSegment
+		}
+		e := segmentEntry{term: string(dict[pos : pos+int(termLen)])}
+		pos += int(termLen)
+		e.docFreq = int(uvarint())
+		e.postingsOffset, e.postingsLen = uvarint(), uvarint()
+		e.freqsOffset, e.freqsLen = uvarint(), uvarint()
+		e.payloadOffset, e.payloadLen = uvarint(), uvarint()
+		if pos < 0 ||
+			!section(e.postingsOffset, e.postingsLen) ||
+			!section(e.freqsOffset, e.freqsLen) ||
+			!section(e.payloadOffset, e.payloadLen) ||
+			(e.freqsLen > 0 && e.payloadLen > 0) ||
+			(i > 0 && r.entries[i-1].term >= e.term) {
+			return ErrCorruptSegment
+		}
+		// the checksum is fine, so this only catches bugs
+		n, _, err := decodePostingsHeader(r.postings(e))
+		if err != nil || n != e.docFreq {
+			return ErrCorruptSegment
+		}
+		r.entries[i] = e
+	}
+	return nil
+}
+
+// Returns the number of documents in the index
+func (r *SegmentReader) TotalDocs() int {
+	return r.totalDocs
+}
+
+// Returns all terms in sorted order
+func (r *SegmentReader) Terms() []string {
+	out := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		out[i] = e.term
+	}
+	return out
+}
+
+func (r *SegmentReader) find(t string) (segmentEntry, bool) {
+	i := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].term >= t
+	})
+	if i < len(r.entries) && r.entries[i].term == t {
+		return r.entries[i], true
+	}
+	return segmentEntry{}, false
+}
+
+func (r *SegmentReader) postings(e segmentEntry) []byte {
+	return r.data[e.postingsOffset : e.postingsOffset+e.postingsLen]
+}
+
+// Returns the postings of the term, nil if it is not in the segment,
+// ErrCorruptSegment if they can not be decoded
+func (r *SegmentReader) Postings(t string) ([]int32, error) {
+	if r.data == nil {
+		return nil, ErrSegmentClosed
+	}
+	e, ok := r.find(t)
+	if !ok {
+		return nil, nil
+	}
+	postings, err := DecodePostings(r.postings(e))
+	if err != nil || len(postings) != e.docFreq {
+		return nil, ErrCorruptSegment
+	}
+	return postings, nil
+}
+
+// Returns the term frequency of each document in the postings, nil if
+// all of them are 1, ErrCorruptSegment if they can not be decoded
+func (r *SegmentReader) Freqs(t string) ([]int32, error) {
+	if r.data == nil {
+		return nil, ErrSegmentClosed
+	}
+	e, ok := r.find(t)
+	if !ok || e.freqsLen == 0 {
+		return nil, nil
+	}
+	freqs, err := decodeInts(r.data[e.freqsOffset : e.freqsOffset+e.freqsLen])
+	if err != nil || len(freqs) != e.docFreq {
+		return nil, ErrCorruptSegment
+	}
+	return freqs, nil
+}
+
+// Returns copy of the payload of the term, nil if it has none
+func (r *SegmentReader) Payload(t string) ([]byte, error) {
+	if r.data == nil {
+		return nil, ErrSegmentClosed
+	}
+	e, ok := r.find(t)
+	if !ok || e.payloadLen == 0 {
+		return nil, nil
+	}
+	out := make([]byte, e.payloadLen)
+	copy(out, r.data[e.payloadOffset:e.payloadOffset+e.payloadLen])
+	return out, nil
+}
+
+// Creates query for the term: PayloadTerm if it has payload,
+// CompressedTerm that decodes the postings (and the frequencies when
+// scoring) while iterating otherwise, if the term is not in the
+// segment it matches nothing. Corrupt blocks found while iterating are
+// returned by Err() of the query
+//
+// The query keeps the mapped segment alive after the reader is
+// closed, so Close() the query when done with it
+func (r *SegmentReader) Term(t string) (Query, error) {
+	if r.data == nil {
+		return nil, ErrSegmentClosed
+	}
+	e, ok := r.find(t)
+	if !ok {
+		return Term(r.totalDocs, t, []int32{}), nil
+	}
+	if e.payloadLen > 0 {
+		postings, err := r.Postings(t)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := r.Payload(t)
+		if err != nil {
+			return nil, err
+		}
+		return PayloadTerm(r.totalDocs, t, postings, payload), nil
+	}
+
+	var freqs []byte
+	if e.freqsLen > 0 {
+		freqs = r.data[e.freqsOffset : e.freqsOffset+e.freqsLen]
+	}
+	q, err := compressedTerm(r.totalDocs, t, r.postings(e), freqs)
+	if err != nil {
+		return nil, ErrCorruptSegment
+	}
+	if r.mapping != nil {
+		if !r.mapping.acquire() {
+			return nil, ErrSegmentClosed
+		}
+		q.mapping = r.mapping
+	}
+	return q, nil
+}
@@ -0,0 +1,273 @@
+package query
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSegment(t *testing.T) []byte {
+	w := NewIndexWriter()
+	for _, d := range []int32{1, 3, 5, 7} {
+		if err := w.Add("name:amsterdam", d, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Add("name:berlin", 2, 3)
+	w.Add("name:berlin", 4, 1)
+	w.Add("name:berlin", 4, 20)
+	w.Add("country:nl", 1, 1)
+	w.Add("country:nl", 9, 1)
+	w.SetPayload("country:nl", []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	w.SetPayload("empty", nil)
+
+	var b bytes.Buffer
+	n, err := w.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != b.Len() {
+		t.Fatalf("%d != %d", n, b.Len())
+	}
+	return b.Bytes()
+}
+
+func readTerm(t *testing.T, r *SegmentReader, term string) Query {
+	q, err := r.Term(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func readPostings(t *testing.T, r *SegmentReader, term string) []int32 {
+	postings, err := r.Postings(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postings
+}
+
+func readFreqs(t *testing.T, r *SegmentReader, term string) []int32 {
+	freqs, err := r.Freqs(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return freqs
+}
+
+func readPayload(t *testing.T, r *SegmentReader, term string) []byte {
+	payload, err := r.Payload(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestSegment(t *testing.T) {
+	data := newTestSegment(t)
+	r, err := NewSegmentReader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.TotalDocs() != 10 {
+		t.Fatal("total")
+	}
+	terms := r.Terms()
+	if len(terms) != 4 || terms[0] != "country:nl" || terms[3] != "name:berlin" {
+		t.Fatal(terms)
+	}
+	eq(t, []int32{1, 3, 5, 7}, readPostings(t, r, "name:amsterdam"))
+	eq(t, []int32{}, readPostings(t, r, "empty"))
+	if readPostings(t, r, "name:paris") != nil || readFreqs(t, r, "name:amsterdam") != nil || readPayload(t, r, "name:amsterdam") != nil {
+		t.Fatal("missing")
+	}
+	eq(t, []int32{3, 20}, readFreqs(t, r, "name:berlin"))
+	if !bytes.Equal(readPayload(t, r, "country:nl"), []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatal("payload")
+	}
+
+	eq(t, []int32{1, 3, 5, 7}, query(readTerm(t, r, "name:amsterdam")))
+	eq(t, []int32{}, query(readTerm(t, r, "name:paris")))
+	eqF(t, queryScores(Term(10, "name:amsterdam", []int32{1, 3, 5, 7})), queryScores(readTerm(t, r, "name:amsterdam")))
+
+	// the frequencies stay compressed
+	berlin := readTerm(t, r, "name:berlin").(*CompressedTermQuery)
+	eqF(t,
+		queryScores(TermTF(10, 5, "name:berlin", []int32{2<<5 | 2, 4<<5 | 19})),
+		queryScores(berlin),
+	)
+	eq(t, []int32{2, 4}, query(readTerm(t, r, "name:berlin")))
+	if berlin.UpperBound() != TermTF(10, 5, "name:berlin", []int32{2<<5 | 2, 4<<5 | 19}).UpperBound() {
+		t.Fatal("upper bound")
+	}
+	if _, ok := readTerm(t, r, "country:nl").(*PayloadTermQuery); !ok {
+		t.Fatal("payload term")
+	}
+
+	eq(t, []int32{1}, query(And(readTerm(t, r, "country:nl"), readTerm(t, r, "name:amsterdam"))))
+}
+
+func TestSegmentFreqs(t *testing.T) {
+	w := NewIndexWriter()
+	freqs := map[int32]int32{}
+	for i, docId := range uniquePostingsList(5000) {
+		freqs[docId] = int32(1 + i%7)
+		if err := w.Add("a", docId, freqs[docId]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Add("b", docId/2, 1+int32(i%3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewSegmentReader(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := readTerm(t, r, "a")
+	for q.Next() != NO_MORE {
+		tf := float32(freqs[q.GetDocId()])
+		if q.Score() != (TFIDF{}).Score(computeIDF(r.TotalDocs(), len(freqs)), tf, q.GetDocId()) {
+			t.Fatalf("doc %d: tf %f", q.GetDocId(), tf)
+		}
+	}
+
+	// the block upper bounds come from the skip table of the frequencies
+	for _, k := range []int{1, 10, 100} {
+		expected, _ := TopK(Or(readTerm(t, r, "a"), readTerm(t, r, "b")), k)
+		hits, _ := TopK(WAND(readTerm(t, r, "a"), readTerm(t, r, "b")), k)
+		eqHits(t, expected, hits)
+	}
+}
+
+func TestSegmentCorrupt(t *testing.T) {
+	data := newTestSegment(t)
+	for i := range data {
+		c := make([]byte, len(data))
+		copy(c, data)
+		c[i] ^= 0x10
+		if _, err := NewSegmentReader(c); err == nil {
+			t.Fatalf("byte %d flipped but no error", i)
+		}
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err := NewSegmentReader(data[:i]); err == nil {
+			t.Fatalf("truncated at %d but no error", i)
+		}
+	}
+
+	w := NewIndexWriter()
+	if err := w.Add("a", 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("a", 4, 1); err == nil {
+		t.Fatal("expected out of order error")
+	}
+	if err := w.Add("a", -1, 1); err == nil {
+		t.Fatal("expected negative error")
+	}
+	if err := w.Add("a", 6, 0); err == nil {
+		t.Fatal("expected frequency error")
+	}
+
+	// payload term does not score with the frequencies
+	if err := w.Add("a", 6, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetPayload("a", []byte{1}); err == nil {
+		t.Fatal("expected payload error")
+	}
+	if err := w.SetPayload("p", []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add("p", 1, 2); err == nil {
+		t.Fatal("expected frequency error")
+	}
+}
+
+func TestSegmentFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "0.seg")
+	w := NewIndexWriter().SetTotalDocs(1000)
+	for i := int32(0); i < 100; i++ {
+		w.Add("even", i*2, 1)
+	}
+	if err := w.WriteFile(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatal("temporary file left")
+	}
+
+	r, err := OpenSegment(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalDocs() != 1000 || len(readPostings(t, r, "even")) != 100 {
+		t.Fatal("read")
+	}
+	even := readTerm(t, r, "even")
+	eq(t, readPostings(t, r, "even"), query(even))
+	even.Close()
+
+	// the queries keep the mapping after the reader is closed
+	q := readTerm(t, r, "even")
+	clone := q.Clone()
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Postings("even"); err != ErrSegmentClosed {
+		t.Fatal(err)
+	}
+	if _, err := r.Freqs("even"); err != ErrSegmentClosed {
+		t.Fatal(err)
+	}
+	if _, err := r.Payload("even"); err != ErrSegmentClosed {
+		t.Fatal(err)
+	}
+	if _, err := r.Term("even"); err != ErrSegmentClosed {
+		t.Fatal(err)
+	}
+	if len(query(q)) != 100 || q.Close() != nil || q.Close() != nil {
+		t.Fatal("query after close")
+	}
+	q.Reset()
+	if len(query(q)) != 100 || q.Close() != nil {
+		t.Fatal("reset")
+	}
+	if len(query(clone)) != 100 || clone.Close() != nil {
+		t.Fatal("clone")
+	}
+	// all closed, the segment is unmapped
+	q.Reset()
+	if q.Next() != NO_MORE || q.Err() != ErrSegmentClosed {
+		t.Fatal("unmapped")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "short"), []byte{1, 2, 3}, 0600)
+	if _, err := OpenSegment(filepath.Join(dir, "short")); err != ErrCorruptSegment {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenSegment(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
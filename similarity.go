@@ -0,0 +1,81 @@
+package query
+
+import "math"
+
+// Computes the score of a term, all term level queries (Term, TermTF,
+// FileTerm, PayloadTerm, PositionalTerm) accept it via SetSimilarity()
+// and default to TFIDF
+type Similarity interface {
+	// Collection level weight of the term, computed once per query
+	IDF(totalDocumentsInIndex, documentsMatching int) float32
+
+	// Per document weight of the term, tf is the term frequency in
+	// the document (always 1 for terms that dont store frequencies)
+	Score(idf float32, tf float32, docId int32) float32
+
+	// Upper bound of Score() for any document in which the term
+	// frequency is at most tf, used for dynamic pruning (see WAND)
+	MaxScore(idf float32, tf float32) float32
+}
+
+// tf*idf, idf is log(1 + N/d)
+type TFIDF struct{}
+
+func (TFIDF) IDF(totalDocumentsInIndex, documentsMatching int) float32 {
+	return computeIDF(totalDocumentsInIndex, documentsMatching)
+}
+
+func (TFIDF) Score(idf float32, tf float32, docId int32) float32 {
+	return tf * idf
+}
+
+func (TFIDF) MaxScore(idf float32, tf float32) float32 {
+	return tf * idf
+}
+
+// Every matching document scores 1, so the score is the boost
+type ConstantSimilarity struct{}
+
+func (ConstantSimilarity) IDF(totalDocumentsInIndex, documentsMatching int) float32 {
+	return 1
+}
+
+func (ConstantSimilarity) Score(idf float32, tf float32, docId int32) float32 {
+	return 1
+}
+
+func (ConstantSimilarity) MaxScore(idf float32, tf float32) float32 {
+	return 1
+}
+
+type Classic struct {
+	norms Norms
+}
+
+// Creates the classic lucene similarity (without query norm and
+// coord), score is sqrt(tf) * idf^2 * 1/sqrt(length), idf is
+// 1 + log((N+1)/(d+1)), so it is at least 1 when d <= N, if norms is
+// nil the length normalization is disabled
+func NewClassic(norms Norms) *Classic {
+	return &Classic{norms: norms}
+}
+
+func (s *Classic) IDF(totalDocumentsInIndex, documentsMatching int) float32 {
+	return float32(1 + math.Log(float64(totalDocumentsInIndex+1)/float64(documentsMatching+1)))
+}
+
+func (s *Classic) Score(idf float32, tf float32, docId int32) float32 {
+	score := float32(math.Sqrt(float64(tf))) * idf * idf
+	if s.norms != nil {
+		length := s.norms.Length(docId)
+		if length > 0 {
+			score *= float32(1 / math.Sqrt(float64(length)))
+		}
+	}
+	return score
+}
+
+// the length normalization is at most 1
+func (s *Classic) MaxScore(idf float32, tf float32) float32 {
+	return float32(math.Sqrt(float64(tf))) * idf * idf
+}
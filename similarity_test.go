@@ -0,0 +1,53 @@
+package query
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	eqF(t,
+		queryScores(Term(10, "x", []int32{1, 2, 3})),
+		queryScores(Term(10, "x", []int32{1, 2, 3}).SetSimilarity(TFIDF{})),
+	)
+
+	eqF(t,
+		queryScores(TermTF(10, 4, "x", termsWithFrequencies(2, []int32{1, 2, 3}))),
+		queryScores(TermTF(10, 4, "x", termsWithFrequencies(2, []int32{1, 2, 3})).SetSimilarity(TFIDF{})),
+	)
+
+	eqF(t, []float32{3, 3}, queryScores(Term(10, "x", []int32{1, 2}).SetSimilarity(ConstantSimilarity{}).SetBoost(3)))
+	eqF(t, []float32{1, 1}, queryScores(TermTF(10, 4, "x", termsWithFrequencies(7, []int32{1, 2})).SetSimilarity(ConstantSimilarity{})))
+	eqF(t, []float32{1}, queryScores(PayloadTerm(10, "x", []int32{1}, nil).SetSimilarity(ConstantSimilarity{})))
+	eqF(t, []float32{1}, queryScores(PositionalTerm(10, "x", []int32{1}, [][]int32{{0}}).SetSimilarity(ConstantSimilarity{})))
+	eqF(t, []float32{1}, queryScores(CreateFileTerm(10, "x", []int32{1}).(*FileTermData).SetSimilarity(ConstantSimilarity{})))
+
+	idf := 1 + math.Log(11.0/3.0)
+	eqApprox(t, []float32{
+		float32(math.Sqrt(3) * idf * idf / math.Sqrt(4)),
+		float32(math.Sqrt(3) * idf * idf / math.Sqrt(16)),
+	}, queryScores(TermTF(10, 4, "x", termsWithFrequencies(2, []int32{1, 2})).SetSimilarity(NewClassic(Int32Norms{0, 4, 16}))))
+
+	// positive even when all documents match
+	if idf := NewClassic(nil).IDF(1, 1); idf != 1 {
+		t.Fatalf("classic idf %f", idf)
+	}
+
+	// same tree, different ranking functions
+	ab := func(s Similarity) []float32 {
+		return queryScores(Or(
+			Term(10, "a", []int32{1, 2, 3}).SetSimilarity(s),
+			TermTF(10, 4, "b", termsWithFrequencies(4, []int32{2})).SetSimilarity(s),
+		))
+	}
+	eqF(t, []float32{1, 2, 1}, ab(ConstantSimilarity{}))
+	eqApprox(t, []float32{
+		computeIDF(10, 3),
+		computeIDF(10, 3) + 5*computeIDF(10, 1),
+		computeIDF(10, 3),
+	}, ab(TFIDF{}))
+	bm := ab(NewBM25(1.2, 0.75, nil, 0))
+	if bm[1] <= bm[0] || bm[0] != bm[2] {
+		t.Fatalf("bm25 %v", bm)
+	}
+}
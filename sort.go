@@ -0,0 +1,130 @@
+package query
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Sort key for NewSortingCollector, the hits are sorted by the values
+// of the doc values column, ascending unless Desc is set. Documents
+// without value are sorted last, unless MissingFirst is set
+type SortField struct {
+	Values       *DocValues
+	Desc         bool
+	MissingFirst bool
+}
+
+// returns -1 if a is before b, 1 if after and 0 if they are equal
+func (f SortField) compare(a, b int32) int {
+	va, okA := f.Values.Int64(a)
+	vb, okB := f.Values.Int64(b)
+	if !okA || !okB {
+		if okA == okB {
+			return 0
+		}
+		if okA != f.MissingFirst {
+			return -1
+		}
+		return 1
+	}
+	if va == vb {
+		return 0
+	}
+	if (va < vb) != f.Desc {
+		return -1
+	}
+	return 1
+}
+
+type sortedHits struct {
+	hits   []Hit
+	fields []SortField
+}
+
+// the fields in order, then higher score and then lower document id
+func (s *sortedHits) before(a, b Hit) bool {
+	for _, f := range s.fields {
+		c := f.compare(a.DocId, b.DocId)
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return hitBefore(a, b)
+}
+
+// max heap by sort order, the worst hit is on top
+func (s *sortedHits) Len() int           { return len(s.hits) }
+func (s *sortedHits) Less(i, j int) bool { return s.before(s.hits[j], s.hits[i]) }
+func (s *sortedHits) Swap(i, j int)      { s.hits[i], s.hits[j] = s.hits[j], s.hits[i] }
+func (s *sortedHits) Push(x interface{}) { s.hits = append(s.hits, x.(Hit)) }
+func (s *sortedHits) Pop() interface{} {
+	n := len(s.hits)
+	x := s.hits[n-1]
+	s.hits = s.hits[0 : n-1]
+	return x
+}
+
+type SortingCollector struct {
+	k      int
+	sorted *sortedHits
+	total  int
+}
+
+// Creates collector that keeps the first k hits sorted by the fields
+// instead of the score, e.g. cheapest first and then newest:
+//
+//	NewSortingCollector(10, SortField{Values: price}, SortField{Values: ts, Desc: true})
+//
+// When the fields are equal the higher score wins and then the lower
+// document id. The score can not be used for pruning, so queries like
+// WAND iterate all matching documents. Like NewTopKCollector the heap
+// grows with the hits
+func NewSortingCollector(k int, fields ...SortField) *SortingCollector {
+	if k < 0 {
+		k = 0
+	}
+	return &SortingCollector{
+		k: k,
+		sorted: &sortedHits{
+			hits:   []Hit{},
+			fields: fields,
+		},
+	}
+}
+
+func (c *SortingCollector) Collect(docId int32, score float32) {
+	c.total++
+	hit := Hit{DocId: docId, Score: score}
+	if len(c.sorted.hits) < c.k {
+		heap.Push(c.sorted, hit)
+		return
+	}
+
+	if c.k > 0 && c.sorted.before(hit, c.sorted.hits[0]) {
+		c.sorted.hits[0] = hit
+		heap.Fix(c.sorted, 0)
+	}
+}
+
+// Returns how many documents were collected
+func (c *SortingCollector) TotalHits() int {
+	return c.total
+}
+
+// Returns the first hits in sort order
+func (c *SortingCollector) Hits() []Hit {
+	out := make([]Hit, len(c.sorted.hits))
+	copy(out, c.sorted.hits)
+	sort.Slice(out, func(i, j int) bool {
+		return c.sorted.before(out[i], out[j])
+	})
+	return out
+}
+
+// Returns the first k hits sorted by the fields and the total number
+// of matching documents
+func TopKSorted(q Query, k int, fields ...SortField) ([]Hit, int) {
+	c := NewSortingCollector(k, fields...)
+	Search(q, c)
+	return c.Hits(), c.TotalHits()
+}
@@ -0,0 +1,79 @@
+package query
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSortingCollector(t *testing.T) {
+	price := NewDocValues("price").SetFloat64(1, 9.5).SetFloat64(2, 3).SetFloat64(3, 9.5).SetFloat64(5, 1)
+	ts := NewDocValues("ts").SetInt64(1, 100).SetInt64(2, 200).SetInt64(3, 300).SetInt64(4, 50)
+	all := func() Query {
+		return Term(10, "x", []int32{1, 2, 3, 4, 5})
+	}
+	if hits, total := TopKSorted(all(), math.MaxInt32, SortField{Values: price}); len(hits) != 5 || total != 5 {
+		t.Fatal("all")
+	}
+	docs := func(hits []Hit) []int32 {
+		out := []int32{}
+		for _, h := range hits {
+			out = append(out, h.DocId)
+		}
+		return out
+	}
+
+	hits, total := TopKSorted(all(), 10, SortField{Values: price})
+	eq(t, []int32{5, 2, 1, 3, 4}, docs(hits))
+	if total != 5 {
+		t.Fatal("total")
+	}
+	hits, _ = TopKSorted(all(), 10, SortField{Values: price, Desc: true})
+	eq(t, []int32{1, 3, 2, 5, 4}, docs(hits))
+	hits, _ = TopKSorted(all(), 10, SortField{Values: price, Desc: true, MissingFirst: true})
+	eq(t, []int32{4, 1, 3, 2, 5}, docs(hits))
+	hits, _ = TopKSorted(all(), 3, SortField{Values: price, Desc: true}, SortField{Values: ts, Desc: true})
+	eq(t, []int32{3, 1, 2}, docs(hits))
+	hits, _ = TopKSorted(all(), 2, SortField{Values: ts, MissingFirst: true})
+	eq(t, []int32{5, 4}, docs(hits))
+	hits, total = TopKSorted(all(), 0, SortField{Values: ts})
+	if len(hits) != 0 || total != 5 {
+		t.Fatal("count only")
+	}
+
+	// score is the tie breaker
+	hits, _ = TopKSorted(Or(
+		Term(10, "x", []int32{1, 2, 3, 4, 5}),
+		Term(10, "y", []int32{3}),
+	), 10, SortField{Values: price})
+	eq(t, []int32{5, 2, 3, 1, 4}, docs(hits))
+
+	// no fields is the same as sorting by score
+	rand.Seed(0)
+	hits, _ = TopKSorted(Or(randomTermTF(1000, "a"), randomTermTF(1000, "b")), 50)
+	rand.Seed(0)
+	expected, _ := TopK(Or(randomTermTF(1000, "a"), randomTermTF(1000, "b")), 50)
+	eqHits(t, expected, hits)
+
+	// top k is the same as sorting everything
+	rand.Seed(0)
+	random := NewDocValues("random")
+	for i := 0; i < 5000; i++ {
+		if rand.Intn(5) > 0 {
+			random.SetInt64(int32(i), int64(rand.Intn(100)))
+		}
+	}
+	f := SortField{Values: random, Desc: true}
+	postings := uniquePostingsList(3000)
+	sorted := allHits(Term(5000, "x", postings))
+	sort.Slice(sorted, func(i, j int) bool {
+		c := f.compare(sorted[i].DocId, sorted[j].DocId)
+		if c != 0 {
+			return c < 0
+		}
+		return sorted[i].DocId < sorted[j].DocId
+	})
+	hits, _ = TopKSorted(Term(5000, "x", postings), 100, f)
+	eqHits(t, sorted[:100], hits)
+}
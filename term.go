@@ -9,18 +9,28 @@ import (
 type block struct {
 	maxDoc int32
 	maxIdx int
+	maxTF  float32 // 1 + the biggest stored frequency, see TermTF()
+}
+
+// Postings of a term and their block skip index, it is never modified
+// after NewTermData, so it can be shared between goroutines, each of
+// them creating its own query with Query()
+type TermData struct {
+	term      string
+	postings  []int32
+	blocks    []block
+	totalDocs int
 }
 
 type TermQuery struct {
+	data              *TermData
 	docId             int32
 	cursor            int
-	postings          []int32
-	blocks            []block
 	currentBlockIndex int
 	currentBlock      block
-	term              string
 	idf               float32 // XXX: unnormalized idf
 	boost             float32
+	similarity        Similarity
 }
 
 func computeIDF(N, d int) float32 {
@@ -33,29 +43,19 @@ func computeIDF(N, d int) float32 {
 // splits the postings list into chunks that are binary searched and inside each chunk linearly searching for next advance()
 var TERM_CHUNK_SIZE = 4096
 
-// Basic []int32{} that the whole interface works on top
-// score is IDF (not tf*idf, just 1*idf, since we dont store the term frequency for now)
-// if you dont know totalDocumentsInIndex, which could be the case sometimes, pass any constant > 0
-// WARNING: the query *can not* be reused
-// WARNING: the query it not thread safe
-func Term(totalDocumentsInIndex int, t string, postings []int32) *TermQuery {
-	q := &TermQuery{
-		term:         t,
-		cursor:       -1,
-		postings:     postings,
-		docId:        NOT_READY,
-		currentBlock: block{maxIdx: 0, maxDoc: NOT_READY},
-		idf:          computeIDF(totalDocumentsInIndex, len(postings)),
-		boost:        1,
-	}
+// returns the skip index of the postings, the last document (shifted
+// by freqBits), the index of the last posting and the max term
+// frequency of each chunk, the frequencies are only looked at when
+// there are any, so it is a pass over the postings only for TermTF()
+func termBlocks(postings []int32, freqBits int32) []block {
 	if len(postings) == 0 {
-		q.idf = 0
-		return q
+		return nil
 	}
 
 	chunkSize := TERM_CHUNK_SIZE
+	freqMask := int32(1<<freqBits) - 1
 
-	q.blocks = make([]block, ((len(postings) + chunkSize - 1) / chunkSize)) // ceil
+	blocks := make([]block, ((len(postings) + chunkSize - 1) / chunkSize)) // ceil
 	blockIndex := 0
 
 	for i := 0; i < len(postings); i += chunkSize {
@@ -64,57 +64,145 @@ func Term(totalDocumentsInIndex int, t string, postings []int32) *TermQuery {
 		if maxIdx >= len(postings)-1 {
 			maxIdx = len(postings) - 1
 		}
-		q.blocks[blockIndex] = block{
-			maxDoc: postings[maxIdx],
+		maxFreq := int32(0)
+		if freqMask > 0 {
+			for _, p := range postings[minIdx : maxIdx+1] {
+				if p&freqMask > maxFreq {
+					maxFreq = p & freqMask
+				}
+			}
+		}
+		blocks[blockIndex] = block{
+			maxDoc: postings[maxIdx] >> freqBits,
 			maxIdx: maxIdx,
+			maxTF:  float32(1 + maxFreq),
 		}
 		blockIndex++
 	}
+	return blocks
+}
 
+// Builds the skip index of the postings, use it when the same term is
+// queried many times (or from many goroutines) instead of Term(),
+// which builds it on every call
+func NewTermData(totalDocumentsInIndex int, t string, postings []int32) *TermData {
+	return &TermData{
+		term:      t,
+		postings:  postings,
+		blocks:    termBlocks(postings, 0),
+		totalDocs: totalDocumentsInIndex,
+	}
+}
+
+// Returns the number of documents of the term
+func (d *TermData) Len() int {
+	return len(d.postings)
+}
+
+// Creates query iterating the postings, it is cheap as the postings
+// and the blocks are shared, the query itself is not thread safe
+func (d *TermData) Query() *TermQuery {
+	q := &TermQuery{
+		data:         d,
+		cursor:       -1,
+		docId:        NOT_READY,
+		currentBlock: block{maxIdx: 0, maxDoc: NOT_READY},
+		boost:        1,
+		similarity:   TFIDF{},
+	}
+	if len(d.postings) > 0 {
+		q.idf = computeIDF(d.totalDocs, len(d.postings))
+	}
 	return q
 }
 
+// Basic []int32{} that the whole interface works on top
+// score is IDF (not tf*idf, just 1*idf, since we dont store the term frequency for now)
+// use SetSimilarity() to score with something else
+// if you dont know totalDocumentsInIndex, which could be the case sometimes, pass any constant > 0
+// use Reset() to iterate it again, Clone() for another goroutine (see
+// Query), NewTermData() to share the postings
+func Term(totalDocumentsInIndex int, t string, postings []int32) *TermQuery {
+	return NewTermData(totalDocumentsInIndex, t, postings).Query()
+}
+
 func (t *TermQuery) GetDocId() int32 {
 	return t.docId
 }
 
 func (t *TermQuery) Cost() int {
-	return len(t.postings) - t.cursor
+	return len(t.data.postings) - t.cursor
 }
 
 func (t *TermQuery) String() string {
-	return fmt.Sprintf("%s/%.2f", t.term, t.idf)
+	return fmt.Sprintf("%s/%.2f", t.data.term, t.idf)
 }
 
 func (t *TermQuery) Score() float32 {
-	return t.idf * t.boost
+	return t.similarity.Score(t.idf, 1, t.docId) * t.boost
 }
 
-func (t *TermQuery) findBlock(target int32) int32 {
-	if len(t.blocks) == 0 {
-		return NO_MORE
+// Score with the given similarity instead of TFIDF, the term
+// frequency is always 1
+func (t *TermQuery) SetSimilarity(s Similarity) *TermQuery {
+	t.similarity = s
+	if len(t.data.postings) > 0 {
+		t.idf = s.IDF(t.data.totalDocs, len(t.data.postings))
+	}
+	return t
+}
+
+// Same as SetSimilarity(s)
+func (t *TermQuery) SetBM25(s *BM25) *TermQuery {
+	return t.SetSimilarity(s)
+}
+
+func (t *TermQuery) UpperBound() float32 {
+	if len(t.data.postings) == 0 {
+		return 0
+	}
+	return boostUpperBound(t.similarity.MaxScore(t.idf, 1), t.boost)
+}
+
+// The term frequency is always 1, so all blocks have the same upper bound
+func (t *TermQuery) BlockUpperBound(target int32) (int32, float32) {
+	found := t.searchBlock(target)
+	if found == len(t.data.blocks) {
+		return NO_MORE, 0
 	}
+	return t.data.blocks[found].maxDoc, t.UpperBound()
+}
+
+func (t *TermQuery) Explain() *Explanation {
+	if t.docId == NOT_READY || t.docId == NO_MORE {
+		return explainNotReady(t.docId, "term "+t.data.term)
+	}
+	return explainTerm(t.Score(), t.data.term, t.similarity, t.idf, t.data.totalDocs, len(t.data.postings), 1, t.boost)
+}
 
-	if len(t.blocks)-t.currentBlockIndex < 32 {
-		for i := t.currentBlockIndex; i < len(t.blocks); i++ {
-			current := t.blocks[i]
-			if target <= current.maxDoc {
-				t.currentBlockIndex = i
-				t.currentBlock = current
-				return target
+// Returns the index of the first block that can contain target,
+// starting from the current block, len(t.data.blocks) if none
+func (t *TermQuery) searchBlock(target int32) int {
+	if len(t.data.blocks)-t.currentBlockIndex < 32 {
+		for i := t.currentBlockIndex; i < len(t.data.blocks); i++ {
+			if target <= t.data.blocks[i].maxDoc {
+				return i
 			}
 		}
-		return NO_MORE
+		return len(t.data.blocks)
 	}
 
-	found := sort.Search(len(t.blocks)-t.currentBlockIndex, func(i int) bool {
-		current := t.blocks[i+t.currentBlockIndex]
+	return sort.Search(len(t.data.blocks)-t.currentBlockIndex, func(i int) bool {
+		current := t.data.blocks[i+t.currentBlockIndex]
 		return target <= current.maxDoc
 	}) + t.currentBlockIndex
+}
 
-	if found < len(t.blocks) {
+func (t *TermQuery) findBlock(target int32) int32 {
+	found := t.searchBlock(target)
+	if found < len(t.data.blocks) {
 		t.currentBlockIndex = found
-		t.currentBlock = t.blocks[found]
+		t.currentBlock = t.data.blocks[found]
 		return target
 	}
 	return NO_MORE
@@ -135,7 +223,7 @@ func (t *TermQuery) Advance(target int32) int32 {
 	t.docId = NO_MORE
 
 	for i := t.cursor; i <= t.currentBlock.maxIdx; i++ {
-		x := t.postings[i]
+		x := t.data.postings[i]
 		if x >= target {
 			t.cursor = i
 			t.docId = x
@@ -149,10 +237,10 @@ func (t *TermQuery) Advance(target int32) int32 {
 
 func (t *TermQuery) Next() int32 {
 	t.cursor++
-	if t.cursor >= len(t.postings) {
+	if t.cursor >= len(t.data.postings) {
 		t.docId = NO_MORE
 	} else {
-		t.docId = t.postings[t.cursor]
+		t.docId = t.data.postings[t.cursor]
 	}
 	return t.docId
 }
@@ -169,3 +257,26 @@ func (t *TermQuery) PayloadDecode(p Payload) {
 func (t *TermQuery) AddSubQuery(Query) Query {
 	panic("unsupported")
 }
+
+func (t *TermQuery) Err() error {
+	return nil
+}
+
+// nothing to release
+func (t *TermQuery) Close() error {
+	return nil
+}
+
+func (t *TermQuery) Reset() {
+	t.docId = NOT_READY
+	t.cursor = -1
+	t.currentBlockIndex = 0
+	t.currentBlock = block{maxIdx: 0, maxDoc: NOT_READY}
+}
+
+// The postings and the blocks are shared, see NewTermData()
+func (t *TermQuery) Clone() Query {
+	c := *t
+	c.Reset()
+	return &c
+}
@@ -5,18 +5,69 @@ import (
 	"sort"
 )
 
+// Postings with term frequencies of a term and their block skip
+// index, it is never modified after NewTermTFData, so it can be shared
+// between goroutines, each of them creating its own query with Query()
+type TermTFData struct {
+	term      string
+	postings  []int32
+	blocks    []block
+	totalDocs int
+	freqBits  int32
+	freqMask  int32
+	maxTF     float32
+}
+
 type TermTFQuery struct {
+	data              *TermTFData
 	docId             int32
 	cursor            int
-	postings          []int32
-	blocks            []block
 	currentBlockIndex int
 	currentBlock      block
-	term              string
 	idf               float32 // XXX: unnormalized idf
 	boost             float32
-	freqBits          int32
-	freqMask          int32
+	similarity        Similarity
+}
+
+// Builds the skip index of the postings, see TermTF() for the format
+// and NewTermData() for why
+func NewTermTFData(totalDocumentsInIndex int, freqBits int32, t string, postings []int32) *TermTFData {
+	d := &TermTFData{
+		term:      t,
+		postings:  postings,
+		blocks:    termBlocks(postings, freqBits),
+		totalDocs: totalDocumentsInIndex,
+		freqBits:  freqBits,
+		freqMask:  (1 << freqBits) - 1,
+	}
+	for _, b := range d.blocks {
+		if b.maxTF > d.maxTF {
+			d.maxTF = b.maxTF
+		}
+	}
+	return d
+}
+
+// Returns the number of documents of the term
+func (d *TermTFData) Len() int {
+	return len(d.postings)
+}
+
+// Creates query iterating the postings, it is cheap as the postings
+// and the blocks are shared, the query itself is not thread safe
+func (d *TermTFData) Query() *TermTFQuery {
+	q := &TermTFQuery{
+		data:         d,
+		cursor:       -1,
+		docId:        NOT_READY,
+		currentBlock: block{maxIdx: 0, maxDoc: NOT_READY},
+		boost:        1,
+		similarity:   TFIDF{},
+	}
+	if len(d.postings) > 0 {
+		q.idf = computeIDF(d.totalDocs, len(d.postings))
+	}
+	return q
 }
 
 // Splits the postings list into chunks that are binary searched and inside each
@@ -29,43 +80,10 @@ type TermTFQuery struct {
 // frequency 1
 //
 // if you dont know totalDocumentsInIndex, which could be the case sometimes, pass any constant > 0
-// WARNING: the query *can not* be reused
-// WARNING: the query it not thread safe
+// use Reset() to iterate it again, Clone() for another goroutine (see
+// Query), NewTermTFData() to share the postings
 func TermTF(totalDocumentsInIndex int, freqBits int32, t string, postings []int32) *TermTFQuery {
-	q := &TermTFQuery{
-		term:         t,
-		cursor:       -1,
-		postings:     postings,
-		docId:        NOT_READY,
-		currentBlock: block{maxIdx: 0, maxDoc: NOT_READY},
-		idf:          computeIDF(totalDocumentsInIndex, len(postings)),
-		boost:        1,
-		freqBits:     freqBits,
-		freqMask:     (1 << freqBits) - 1,
-	}
-
-	if len(postings) == 0 {
-		q.idf = 0
-		return q
-	}
-	chunkSize := TERM_CHUNK_SIZE
-
-	q.blocks = make([]block, ((len(postings) + chunkSize - 1) / chunkSize)) // ceil
-	blockIndex := 0
-
-	for i := 0; i < len(postings); i += chunkSize {
-		minIdx := i
-		maxIdx := (minIdx + chunkSize) - 1
-		if maxIdx >= len(postings)-1 {
-			maxIdx = len(postings) - 1
-		}
-		q.blocks[blockIndex] = block{
-			maxDoc: postings[maxIdx] >> q.freqBits,
-			maxIdx: maxIdx,
-		}
-		blockIndex++
-	}
-	return q
+	return NewTermTFData(totalDocumentsInIndex, freqBits, t, postings).Query()
 }
 
 func (t *TermTFQuery) GetDocId() int32 {
@@ -73,11 +91,11 @@ func (t *TermTFQuery) GetDocId() int32 {
 }
 
 func (t *TermTFQuery) Cost() int {
-	return len(t.postings) - t.cursor
+	return len(t.data.postings) - t.cursor
 }
 
 func (t *TermTFQuery) String() string {
-	return fmt.Sprintf("%s/%.2f", t.term, t.idf)
+	return fmt.Sprintf("%s/%.2f", t.data.term, t.idf)
 }
 
 func (t *TermTFQuery) Score() float32 {
@@ -85,35 +103,73 @@ func (t *TermTFQuery) Score() float32 {
 		return 0
 	}
 
-	tf := float32(1 + (t.postings[t.cursor] & t.freqMask))
-	return tf * t.idf * t.boost
+	tf := float32(1 + (t.data.postings[t.cursor] & t.data.freqMask))
+	return t.similarity.Score(t.idf, tf, t.docId) * t.boost
 }
 
-func (t *TermTFQuery) findBlock(target int32) int32 {
-	if len(t.blocks) == 0 {
-		return NO_MORE
+// Score with the given similarity instead of TFIDF, the stored term
+// frequency is used as is, so if you store sqrt(frequency) and use
+// BM25 it will be saturated twice
+func (t *TermTFQuery) SetSimilarity(s Similarity) *TermTFQuery {
+	t.similarity = s
+	if len(t.data.postings) > 0 {
+		t.idf = s.IDF(t.data.totalDocs, len(t.data.postings))
 	}
+	return t
+}
 
-	if len(t.blocks)-t.currentBlockIndex < 32 {
-		for i := t.currentBlockIndex; i < len(t.blocks); i++ {
-			current := t.blocks[i]
-			if target <= current.maxDoc {
-				t.currentBlockIndex = i
-				t.currentBlock = current
-				return target
+// Same as SetSimilarity(s)
+func (t *TermTFQuery) SetBM25(s *BM25) *TermTFQuery {
+	return t.SetSimilarity(s)
+}
+
+func (t *TermTFQuery) UpperBound() float32 {
+	if t.data.maxTF == 0 {
+		return 0
+	}
+	return boostUpperBound(t.similarity.MaxScore(t.idf, t.data.maxTF), t.boost)
+}
+
+func (t *TermTFQuery) BlockUpperBound(target int32) (int32, float32) {
+	found := t.searchBlock(target)
+	if found == len(t.data.blocks) {
+		return NO_MORE, 0
+	}
+	b := t.data.blocks[found]
+	return b.maxDoc, boostUpperBound(t.similarity.MaxScore(t.idf, b.maxTF), t.boost)
+}
+
+func (t *TermTFQuery) Explain() *Explanation {
+	if t.docId == NOT_READY || t.docId == NO_MORE {
+		return explainNotReady(t.docId, "term "+t.data.term)
+	}
+	tf := float32(1 + (t.data.postings[t.cursor] & t.data.freqMask))
+	return explainTerm(t.Score(), t.data.term, t.similarity, t.idf, t.data.totalDocs, len(t.data.postings), tf, t.boost)
+}
+
+// Returns the index of the first block that can contain target,
+// starting from the current block, len(t.data.blocks) if none
+func (t *TermTFQuery) searchBlock(target int32) int {
+	if len(t.data.blocks)-t.currentBlockIndex < 32 {
+		for i := t.currentBlockIndex; i < len(t.data.blocks); i++ {
+			if target <= t.data.blocks[i].maxDoc {
+				return i
 			}
 		}
-		return NO_MORE
+		return len(t.data.blocks)
 	}
 
-	found := sort.Search(len(t.blocks)-t.currentBlockIndex, func(i int) bool {
-		current := t.blocks[i+t.currentBlockIndex]
+	return sort.Search(len(t.data.blocks)-t.currentBlockIndex, func(i int) bool {
+		current := t.data.blocks[i+t.currentBlockIndex]
 		return target <= current.maxDoc
 	}) + t.currentBlockIndex
+}
 
-	if found < len(t.blocks) {
+func (t *TermTFQuery) findBlock(target int32) int32 {
+	found := t.searchBlock(target)
+	if found < len(t.data.blocks) {
 		t.currentBlockIndex = found
-		t.currentBlock = t.blocks[found]
+		t.currentBlock = t.data.blocks[found]
 		return target
 	}
 	return NO_MORE
@@ -134,7 +190,7 @@ func (t *TermTFQuery) Advance(target int32) int32 {
 	t.docId = NO_MORE
 
 	for i := t.cursor; i <= t.currentBlock.maxIdx; i++ {
-		x := t.postings[i] >> t.freqBits
+		x := t.data.postings[i] >> t.data.freqBits
 		if x >= target {
 			t.cursor = i
 			t.docId = x
@@ -148,10 +204,10 @@ func (t *TermTFQuery) Advance(target int32) int32 {
 
 func (t *TermTFQuery) Next() int32 {
 	t.cursor++
-	if t.cursor >= len(t.postings) {
+	if t.cursor >= len(t.data.postings) {
 		t.docId = NO_MORE
 	} else {
-		t.docId = t.postings[t.cursor] >> t.freqBits
+		t.docId = t.data.postings[t.cursor] >> t.data.freqBits
 
 	}
 	return t.docId
@@ -169,3 +225,26 @@ func (t *TermTFQuery) PayloadDecode(p Payload) {
 func (t *TermTFQuery) AddSubQuery(Query) Query {
 	panic("unsupported")
 }
+
+func (t *TermTFQuery) Err() error {
+	return nil
+}
+
+// nothing to release
+func (t *TermTFQuery) Close() error {
+	return nil
+}
+
+func (t *TermTFQuery) Reset() {
+	t.docId = NOT_READY
+	t.cursor = -1
+	t.currentBlockIndex = 0
+	t.currentBlock = block{maxIdx: 0, maxDoc: NOT_READY}
+}
+
+// The postings and the blocks are shared, see NewTermTFData()
+func (t *TermTFQuery) Clone() Query {
+	c := *t
+	c.Reset()
+	return &c
+}
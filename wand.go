@@ -0,0 +1,343 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Implemented by queries that know the maximum score of the block of
+// documents that contains a target document, used by WAND to skip
+// whole blocks (Block-Max WAND)
+type BlockMaxQuery interface {
+	Query
+
+	// Returns the last document of the block containing target and
+	// the maximum score inside the block, does not move the iterator,
+	// returns NO_MORE if there are no documents >= target
+	BlockUpperBound(target int32) (int32, float32)
+}
+
+// Implemented by queries that can skip documents that can not score
+// more than the minimum competitive score
+type PruningQuery interface {
+	Query
+	SetMinCompetitiveScore(float32)
+}
+
+var unknownUpperBound = float32(math.Inf(1))
+
+func boostUpperBound(ub float32, boost float32) float32 {
+	if boost == 0 {
+		return 0
+	}
+	return ub * boost
+}
+
+// upper bound of max + (sum - max) * tieBreaker
+func disMaxUpperBound(max, sum, tieBreaker float32) float32 {
+	if math.IsInf(float64(max), 1) || tieBreaker == 1 {
+		return sum
+	}
+	return max + (sum-max)*tieBreaker
+}
+
+// Scores are summed in different order when computing the bound and
+// the actual score, so leave a bit of room for the rounding errors
+func padUpperBound(ub float32, n int) float32 {
+	return ub * (1 + float32(n)*1e-6)
+}
+
+type WANDQuery struct {
+	queries     []Query
+	upperBounds []float32
+	sorted      []int
+	docId       int32
+	score       float32
+	threshold   float32
+	tieBreaker  float32
+	boost       float32
+}
+
+// Creates WAND (weak and) query, it matches the same documents and
+// has the same score as Or(queries...), but when used with a TopK
+// collector it skips documents that can not make it into the top k,
+// using the UpperBound() of each query and the block upper bounds of
+// the term queries (Block-Max WAND)
+//
+// Keep in mind that with pruning the total hits count is a lower bound
+func WAND(queries ...Query) *WANDQuery {
+	return WANDDisMax(1, queries...)
+}
+
+// Creates WAND query with the same matches and score as
+// DisMax(tieBreaker, queries...)
+func WANDDisMax(tieBreaker float32, queries ...Query) *WANDQuery {
+	return &WANDQuery{
+		queries:    queries,
+		docId:      NOT_READY,
+		threshold:  float32(math.Inf(-1)),
+		tieBreaker: tieBreaker,
+		boost:      1,
+	}
+}
+
+// Documents that can not score more than min are skipped
+func (q *WANDQuery) SetMinCompetitiveScore(min float32) {
+	if min > q.threshold {
+		q.threshold = min
+	}
+}
+
+func (q *WANDQuery) AddSubQuery(sub Query) Query {
+	q.queries = append(q.queries, sub)
+	q.upperBounds = nil
+	return q
+}
+
+func (q *WANDQuery) Cost() int {
+	//XXX: optimistic, assume sets greatly overlap, which of course is not always true
+	max := 0
+	for _, sub := range q.queries {
+		if max < sub.Cost() {
+			max = sub.Cost()
+		}
+	}
+
+	return max
+}
+
+func (q *WANDQuery) GetDocId() int32 {
+	return q.docId
+}
+
+func (q *WANDQuery) Score() float32 {
+	return q.score
+}
+
+func (q *WANDQuery) UpperBound() float32 {
+	sum := float32(0)
+	max := float32(0)
+	for _, s := range q.queries {
+		ub := s.UpperBound()
+		if ub > max {
+			max = ub
+		}
+		sum += ub
+	}
+	return boostUpperBound(disMaxUpperBound(max, sum, q.tieBreaker), q.boost)
+}
+
+func (q *WANDQuery) prepare() {
+	q.upperBounds = make([]float32, len(q.queries))
+	q.sorted = make([]int, len(q.queries))
+	for i, s := range q.queries {
+		q.upperBounds[i] = s.UpperBound()
+		q.sorted[i] = i
+	}
+}
+
+func (q *WANDQuery) competitive(max, sum float32, n int) bool {
+	return padUpperBound(boostUpperBound(disMaxUpperBound(max, sum, q.tieBreaker), q.boost), n) > q.threshold
+}
+
+// keeps q.sorted sorted by the current document of each query
+func (q *WANDQuery) sortByDocId() {
+	for i := 1; i < len(q.sorted); i++ {
+		for j := i; j > 0 && q.queries[q.sorted[j]].GetDocId() < q.queries[q.sorted[j-1]].GetDocId(); j-- {
+			q.sorted[j], q.sorted[j-1] = q.sorted[j-1], q.sorted[j]
+		}
+	}
+}
+
+// Returns the first document >= target that can score above the threshold
+func (q *WANDQuery) nextCompetitive(target int32) int32 {
+	if q.upperBounds == nil {
+		q.prepare()
+	}
+
+	for target != NO_MORE {
+		for _, s := range q.queries {
+			if s.GetDocId() < target {
+				s.Advance(target)
+			}
+		}
+		q.sortByDocId()
+
+		// find the pivot, the first document at which the queries
+		// before it together can beat the threshold
+		max := float32(0)
+		sum := float32(0)
+		pivot := -1
+		for i, idx := range q.sorted {
+			if q.queries[idx].GetDocId() == NO_MORE {
+				break
+			}
+			ub := q.upperBounds[idx]
+			if ub > max {
+				max = ub
+			}
+			sum += ub
+			if q.competitive(max, sum, i+1) {
+				pivot = i
+				break
+			}
+		}
+		if pivot == -1 {
+			target = NO_MORE
+			break
+		}
+
+		pivotDoc := q.queries[q.sorted[pivot]].GetDocId()
+		for pivot+1 < len(q.sorted) && q.queries[q.sorted[pivot+1]].GetDocId() == pivotDoc {
+			pivot++
+		}
+
+		// block max check, all documents until the end of the
+		// smallest block are bounded by the block upper bounds
+		max = 0
+		sum = 0
+		blockEnd := NO_MORE
+		for _, idx := range q.sorted[:pivot+1] {
+			ub := q.upperBounds[idx]
+			if bm, ok := q.queries[idx].(BlockMaxQuery); ok {
+				var end int32
+				end, ub = bm.BlockUpperBound(pivotDoc)
+				if end < blockEnd {
+					blockEnd = end
+				}
+			}
+			if ub > max {
+				max = ub
+			}
+			sum += ub
+		}
+
+		if !q.competitive(max, sum, pivot+1) {
+			next := NO_MORE
+			if blockEnd != NO_MORE {
+				next = blockEnd + 1
+			}
+			if pivot+1 < len(q.sorted) {
+				other := q.queries[q.sorted[pivot+1]].GetDocId()
+				if other < next {
+					next = other
+				}
+			}
+			target = next
+			continue
+		}
+
+		if q.queries[q.sorted[0]].GetDocId() != pivotDoc {
+			// move the queries before the pivot and find the pivot again
+			target = pivotDoc
+			continue
+		}
+
+		q.score = q.scoreCurrent(pivotDoc)
+		if q.score > q.threshold {
+			q.docId = pivotDoc
+			return pivotDoc
+		}
+		target = pivotDoc + 1
+	}
+
+	q.docId = NO_MORE
+	q.score = 0
+	return NO_MORE
+}
+
+func (q *WANDQuery) scoreCurrent(docId int32) float32 {
+	sum := float32(0)
+	max := float32(0)
+	for _, s := range q.queries {
+		if s.GetDocId() == docId {
+			subQueryScore := s.Score()
+			if subQueryScore > max {
+				max = subQueryScore
+			}
+			sum += subQueryScore
+		}
+	}
+	if q.tieBreaker == 1 {
+		return sum * q.boost
+	}
+	return (max + ((sum - max) * q.tieBreaker)) * q.boost
+}
+
+func (q *WANDQuery) Advance(target int32) int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.nextCompetitive(target)
+}
+
+func (q *WANDQuery) Next() int32 {
+	if q.docId == NO_MORE {
+		return NO_MORE
+	}
+	return q.nextCompetitive(q.docId + 1)
+}
+
+func (q *WANDQuery) Explain() *Explanation {
+	if q.docId == NOT_READY || q.docId == NO_MORE {
+		return explainNotReady(q.docId, "wand")
+	}
+
+	details := []*Explanation{}
+	for _, s := range q.queries {
+		if s.GetDocId() == q.docId {
+			details = append(details, s.Explain())
+		} else {
+			details = append(details, explainNoMatch(s.String()))
+		}
+	}
+	if !math.IsInf(float64(q.threshold), -1) {
+		details = append(details, explainMatch(q.threshold, "min competitive score"))
+	}
+	if q.tieBreaker == 1 {
+		return explainBoost(explainMatch(q.score, "wand, sum of:", details...), q.boost)
+	}
+	details = append(details, explainMatch(q.tieBreaker, "tie breaker"))
+	return explainBoost(explainMatch(q.score, "wand, max plus tie breaker * others of:", details...), q.boost)
+}
+
+func (q *WANDQuery) String() string {
+	out := []string{}
+	for _, v := range q.queries {
+		out = append(out, v.String())
+	}
+	if q.tieBreaker == 1 {
+		return "{" + strings.Join(out, " WAND ") + "}"
+	}
+	return fmt.Sprintf("{%s}~%.2f", strings.Join(out, " WAND "), q.tieBreaker)
+}
+
+func (q *WANDQuery) SetBoost(b float32) Query {
+	q.boost = b
+	return q
+}
+
+func (q *WANDQuery) PayloadDecode(p Payload) {
+	panic("unsupported")
+}
+
+func (q *WANDQuery) Err() error {
+	return firstErr(q.queries)
+}
+
+func (q *WANDQuery) Close() error {
+	return closeAll(q.queries)
+}
+
+func (q *WANDQuery) Reset() {
+	resetAll(q.queries)
+	q.upperBounds = nil
+	q.docId = NOT_READY
+	q.score = 0
+	q.threshold = float32(math.Inf(-1))
+}
+
+func (q *WANDQuery) Clone() Query {
+	return WANDDisMax(q.tieBreaker, cloneAll(q.queries)...).SetBoost(q.boost)
+}
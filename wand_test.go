@@ -0,0 +1,174 @@
+package query
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestUpperBound(t *testing.T) {
+	queries := func() []Query {
+		rand.Seed(0)
+		return []Query{
+			Term(10, "x", []int32{1, 2, 3}).SetBoost(2),
+			randomTermTF(1000, "a"),
+			randomTermTF(1000, "b").SetSimilarity(NewBM25(1.2, 0.75, Int32Norms{}, 10)),
+			randomTermTF(1000, "c").SetSimilarity(NewClassic(nil)),
+			CreateFileTerm(10, "x", []int32{1, 2, 3}),
+			Constant(3, Term(10, "x", []int32{1, 2, 3})),
+			Or(randomTermTF(100, "a"), randomTermTF(100, "b")),
+			And(randomTermTF(10000, "a"), Or(randomTermTF(10000, "b"), randomTermTF(10000, "c"))),
+			DisMax(0.3, randomTermTF(100, "a"), randomTermTF(100, "b")).SetBoost(3),
+			WANDDisMax(0.3, randomTermTF(100, "a"), randomTermTF(100, "b")),
+		}
+	}
+
+	for _, q := range queries() {
+		ub := q.UpperBound()
+		for q.Next() != NO_MORE {
+			if q.Score() > ub {
+				t.Fatalf("%s: %f > %f", q.String(), q.Score(), ub)
+			}
+		}
+	}
+
+	if Term(10, "x", []int32{}).UpperBound() != 0 || Term(10, "x", []int32{1}).SetBoost(0).UpperBound() != 0 {
+		t.Fatal("zero")
+	}
+
+	// the block max frequencies are known when the data is built
+	old := TERM_CHUNK_SIZE
+	TERM_CHUNK_SIZE = 2
+	data := NewTermTFData(10, 2, "x", []int32{1<<2 | 1, 2<<2 | 3, 5 << 2})
+	TERM_CHUNK_SIZE = old
+	if data.blocks[0].maxTF != 4 || data.blocks[1].maxTF != 1 || data.maxTF != 4 {
+		t.Fatalf("block max tf %v", data.blocks)
+	}
+	q := data.Query().SetSimilarity(ConstantSimilarity{})
+	if doc, _ := q.BlockUpperBound(3); doc != 5 {
+		t.Fatalf("block upper bound %d", doc)
+	}
+
+	idx := newPositionalIndex("a b")
+	if Phrase(idx.terms("a b")...).UpperBound() != unknownUpperBound {
+		t.Fatal("phrase")
+	}
+	if DisMax(0, Phrase(idx.terms("a b")...), idx.term("a")).UpperBound() != unknownUpperBound {
+		t.Fatal("dismax")
+	}
+}
+
+func TestWAND(t *testing.T) {
+	old := TERM_CHUNK_SIZE
+	defer func() {
+		TERM_CHUNK_SIZE = old
+	}()
+
+	for _, chunk := range []int{4, 128, 4096} {
+		TERM_CHUNK_SIZE = chunk
+		for _, k := range []int{0, 1, 10, 100} {
+			for _, tie := range []float32{1, 0.5, 0} {
+				terms := func() []Query {
+					rand.Seed(int64(k + chunk))
+					return []Query{
+						randomTermTF(20000, "a"),
+						randomTermTF(5000, "b"),
+						randomTermTF(50, "c"),
+						Term(1000000, "d", uniquePostingsList(100)),
+						And(randomTermTF(20000, "e"), randomTermTF(20000, "f")),
+					}
+				}
+
+				var expected []Hit
+				var total int
+				if tie == 1 {
+					expected, total = TopK(Or(terms()...), k)
+					eqHits(t, allHits(Or(terms()...)), allHits(WAND(terms()...)))
+				} else {
+					expected, total = TopK(DisMax(tie, terms()...), k)
+					eqHits(t, allHits(DisMax(tie, terms()...)), allHits(WANDDisMax(tie, terms()...)))
+				}
+
+				hits, pruned := TopK(WANDDisMax(tie, terms()...), k)
+				eqHits(t, expected, hits)
+				if k > 0 && pruned >= total {
+					t.Fatalf("no pruning, k: %d, chunk: %d, %d >= %d", k, chunk, pruned, total)
+				}
+				if k == 0 && pruned != total {
+					t.Fatalf("count only %d != %d", pruned, total)
+				}
+			}
+		}
+	}
+
+	eq(t, []int32{}, query(WAND()))
+	eq(t, []int32{1, 2, 3, 5}, query(WAND(
+		Term(10, "a", []int32{1, 3}),
+		Term(10, "b", []int32{}),
+	).AddSubQuery(Term(10, "c", []int32{2, 3, 5}))))
+
+	w := WAND(Term(10, "a", []int32{1, 3, 5, 7}), Term(10, "b", []int32{3, 7}))
+	if w.Advance(4) != 5 {
+		t.Fatal("advance")
+	}
+	w.SetMinCompetitiveScore(computeIDF(10, 4))
+	if w.Next() != 7 || w.Score() != computeIDF(10, 4)+computeIDF(10, 2) {
+		t.Fatal("threshold")
+	}
+	if w.Next() != NO_MORE {
+		t.Fatal("exhausted")
+	}
+}
+
+func TestMaxScore(t *testing.T) {
+	old := TERM_CHUNK_SIZE
+	defer func() {
+		TERM_CHUNK_SIZE = old
+	}()
+
+	for _, chunk := range []int{4, 4096} {
+		TERM_CHUNK_SIZE = chunk
+		for _, k := range []int{0, 1, 10, 100} {
+			terms := func() []Query {
+				rand.Seed(int64(k + chunk))
+				return []Query{
+					randomTermTF(20000, "a"),
+					randomTermTF(5000, "b"),
+					randomTermTF(50, "c"),
+					Constant(0.5, Term(1000000, "d", uniquePostingsList(30000))),
+					Term(1000000, "e", uniquePostingsList(100)).SetBoost(3),
+					And(randomTermTF(20000, "f"), randomTermTF(20000, "g")),
+				}
+			}
+
+			eqHits(t, allHits(Or(terms()...)), allHits(MaxScore(terms()...)))
+
+			expected, total := TopK(Or(terms()...), k)
+			hits, pruned := TopK(MaxScore(terms()...).SetBoost(1), k)
+			eqHits(t, expected, hits)
+			if k > 0 && pruned >= total {
+				t.Fatalf("no pruning, k: %d, chunk: %d, %d >= %d", k, chunk, pruned, total)
+			}
+			if k == 0 && pruned != total {
+				t.Fatalf("count only %d != %d", pruned, total)
+			}
+		}
+	}
+
+	eq(t, []int32{}, query(MaxScore()))
+	eq(t, []int32{1, 2, 3, 5}, query(MaxScore(
+		Term(10, "a", []int32{1, 3}),
+		Term(10, "b", []int32{}),
+	).AddSubQuery(Term(10, "c", []int32{2, 3, 5}))))
+
+	m := MaxScore(Term(10, "a", []int32{1, 3, 5, 7}), Term(10, "b", []int32{3, 7}))
+	if m.Advance(4) != 5 {
+		t.Fatal("advance")
+	}
+	m.SetMinCompetitiveScore(computeIDF(10, 4))
+	if m.Next() != 7 || m.Score() != computeIDF(10, 4)+computeIDF(10, 2) {
+		t.Fatal("threshold")
+	}
+	if m.Next() != NO_MORE {
+		t.Fatal("exhausted")
+	}
+}